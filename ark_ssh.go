@@ -3,7 +3,6 @@ package arkssh
 import (
 	"errors"
 	"fmt"
-	"os"
 	"strings"
 	"sync"
 
@@ -22,6 +21,12 @@ const (
 	LINUX        = "linux"
 	DIPU         = "dptech"
 	ZTE          = "zte"
+	JUNIPER      = "juniper"
+	ARISTA       = "arista"
+	RUIJIE       = "ruijie"
+	FORTINET     = "fortinet"
+	PALOALTO     = "paloalto"
+	HILLSTONE    = "hillstone"
 	PROMPT       = `\n<[^!]{1,100}>\s*$|\n\[[^\]]{1,100}\]\s*$|\n[^!\n ]{3,30}[^ \n]#\s*$|\n.*\[[^\]]{1,100}\]#\s*$`
 )
 
@@ -35,6 +40,7 @@ type Device struct {
 	Port           string `bson:"port,omitempty" json:"port,omitempty"`
 	Username       string `bson:"username,omitempty" json:"username,omitempty"`
 	Password       string `bson:"password,omitempty" json:"password,omitempty"`
+	EnablePassword string `bson:"enable_password,omitempty" json:"enable_password,omitempty"` //提权（enable/super）密码，为空则不提权
 	Brand          string `bson:"brand,omitempty" json:"brand,omitempty"`
 	Status         string `bson:"status,omitempty" json:"status,omitempty"`
 	SSHStatus      string `bson:"ssh_status,omitempty" json:"ssh_status,omitempty"`
@@ -44,8 +50,14 @@ type Device struct {
 	NotPong        int    `bson:"not_pong,omitempty" json:"not_pong,omitempty"`                 //探测主机22端口连续失败次数
 	ProdTestSimple bool   `bson:"prod_test_simple,omitempty" json:"prod_test_simple,omitempty"` //是否为生产环境测试用例使用设备..
 
+	// 设备识别相关
+	BrandDetect *DetectResult `bson:"brand_detect,omitempty" json:"brand_detect,omitempty"` //品牌识别的可信度和依据
+	Facts       *Facts        `bson:"facts,omitempty" json:"facts,omitempty"`               //设备型号、版本、序列号等基础信息
+	Role        *DeviceRole   `bson:"role,omitempty" json:"role,omitempty"`                 //根据登录提示符中的主机名得到的角色、站点等分类
+
 	// 推送命令相关
 	Cmds                     []string               `bson:"cmds,omitempty" json:"cmds,omitempty"`
+	Context                  string                 `bson:"context,omitempty" json:"context,omitempty"` //防火墙的虚拟系统（fortinet的VDOM、paloalto/hillstone的vsys），为空则不切换
 	Timeout                  int                    `bson:"timeout,omitempty" json:"timeout,omitempty"`
 	SendStatus               string                 `bson:"send_status,omitempty" json:"send_status,omitempty"` //命令推送的状态，成功为success
 	RawResult                string                 `bson:"raw_result,omitempty" json:"raw_result,omitempty"`   //原始的回显
@@ -56,6 +68,10 @@ type Device struct {
 	TextFsmTemplateFilenames []string               `bson:"textfsm_templates,omitempty" json:"textfsm_templates,omitempty"`
 	TextFsmContent           string                 `bson:"textfsm_content,omitempty" json:"textfsm_content,omitempty"`
 	TextFsmResults           map[string]interface{} `bson:"textfsm_results,omitempty" json:"textfsm_results,omitempty"`
+	AutoTextFsm              bool                   `bson:"auto_textfsm,omitempty" json:"auto_textfsm,omitempty"`       //是否按模板索引自动解析每条命令的回显，结果写入MapResult
+	CmdTextFsm               map[string]CmdTextFsm  `bson:"cmd_textfsm,omitempty" json:"cmd_textfsm,omitempty"`         //以命令为key，指定单条命令回显使用的模板，优先于模板索引
+	NormalizeRules           []NormalizeRule        `bson:"normalize_rules,omitempty" json:"normalize_rules,omitempty"` //配置备份类命令额外追加的归一化规则
+	RoleRules                []RoleRule             `bson:"role_rules,omitempty" json:"role_rules,omitempty"`           //该设备额外的主机名分类规则，优先于全局规则
 	// 登录验证相关
 	LoginSuccessTimes       int `bson:"login_success_times,omitempty" json:"login_success_times,omitempty"`     //登录成功次数
 	LoginTotalTimes         int `bson:"login_total_times,omitempty" json:"login_total_times"`                   //登录总次数
@@ -64,8 +80,16 @@ type Device struct {
 
 // 单个命令的执行情况
 type OneCMDRes struct {
-	RES    string `bson:"res,omitempty" json:"res,omitempty"`
-	Status string `bson:"status,omitempty" json:"status,omitempty"`
+	RES            string                   `bson:"res,omitempty" json:"res,omitempty"`
+	Status         string                   `bson:"status,omitempty" json:"status,omitempty"`
+	Template       string                   `bson:"template,omitempty" json:"template,omitempty"`               //解析该命令回显使用的textfsm模板
+	TextFsmResults []map[string]interface{} `bson:"textfsm_results,omitempty" json:"textfsm_results,omitempty"` //该命令回显的textfsm解析结果
+}
+
+// 单个命令回显的解析方式，Content不为空时忽略Template
+type CmdTextFsm struct {
+	Template string `bson:"template,omitempty" json:"template,omitempty"` //模板名称，从品牌目录下读取
+	Content  string `bson:"content,omitempty" json:"content,omitempty"`   //内联的模板内容
 }
 
 /**
@@ -112,9 +136,11 @@ func (d *Device) GetBrand() (string, error) {
 		LogError("获取会话错误:%s", err)
 		return "", err
 	}
-	brand := sshSession.GetSSHBrand()
+	detect := sshSession.DetectBrand()
+	brand := detect.Brand
 	d.Brand = brand
-	LogDebug("获取设备brand成功,ipPort:%s,brand:%s", ipPort, brand)
+	d.BrandDetect = &detect
+	LogDebug("获取设备brand成功,ipPort:%s,brand:%s,confidence:%s", ipPort, brand, detect.Confidence)
 
 	return brand, nil
 }
@@ -141,7 +167,7 @@ func (d *Device) RunCmdWithoutBrand() error {
 	}
 	sshSession.WriteChannel(d.Cmds...)
 	result, _ := sshSession.ReadChannelTiming(10)
-	d.RawResult = filterResult(result, d.Cmds[0])
+	d.RawResult = filterResult(result, d.Cmds[0], d.normalizeRules(d.Cmds[0]))
 	return nil
 }
 
@@ -183,14 +209,37 @@ func (d *Device) RunCmdWithBrand(timeOut int) error {
 		LogError("获取会话错误:%s", d.SendStatus)
 		return err
 	}
-	//
+	// 根据登录时的提示符分类，不需要发送命令
+	d.Role = sshSession.ClassifyRole(d.RoleRules...)
+	// 需要提权的设备先按Driver定义的方式提权
+	if d.EnablePassword != "" {
+		if err := sshSession.Escalate(d.EnablePassword); err != nil {
+			d.SendStatus = fmt.Sprintf("%s,IP为%s", err.Error(), d.IP)
+			LogError("提权错误:%s", d.SendStatus)
+			return err
+		}
+	}
 	successNum := 0
 	rawRes := ""
 	mapRes := make(map[string]OneCMDRes)
 	// 循环命令，依次向管道推送
+	driver := sshSession.Driver()
+	// 指定了虚拟系统的设备先切换到对应的VDOM/vsys，执行完毕后退出，避免影响缓存的会话
+	if d.Context != "" {
+		if err := sshSession.EnterContext(d.Context); err != nil {
+			d.SendStatus = fmt.Sprintf("%s,IP为%s", err.Error(), d.IP)
+			LogError("切换虚拟系统错误:%s", d.SendStatus)
+			return err
+		}
+		defer sshSession.ExitContext()
+	}
 	for _, cmd := range d.Cmds {
 		var one OneCMDRes
-		sshSession.WriteChannel(strings.TrimSpace(cmd)) //去除空白字符
+		sendCmd := strings.TrimSpace(cmd) //去除空白字符
+		if driver != nil {
+			sendCmd = driver.FormatCmd(sendCmd)
+		}
+		sshSession.WriteChannel(sendCmd)
 		ok := false
 		// 单词命令的回显，是否推送成功
 		one.RES, ok = sshSession.ReadChannelTiming(timeOut)
@@ -207,7 +256,9 @@ func (d *Device) RunCmdWithBrand(timeOut int) error {
 			one.Status = "读回显遇到未知错误"
 		}
 		// 对单次命令的回显进行格式化操作
-		one.RES = filterResult(one.RES, cmd)
+		one.RES = filterResult(one.RES, sendCmd, d.normalizeRules(cmd))
+		// 按指定的模板或模板索引解析单条命令的回显
+		d.parseCmdResult(cmd, &one)
 		mapRes[cmd] = one
 		rawRes += one.RES
 	}
@@ -255,19 +306,52 @@ func (d *Device) RunCmdWithBrand(timeOut int) error {
 		}
 		d.TextFsmResults = parserRes
 	}
-	
+
 	return nil
 }
 
+/**
+ * 解析单条命令的回显：优先使用CmdTextFsm中为该命令指定的内联模板或模板名称，开启AutoTextFsm时再按模板索引查找
+ * @param	cmd，执行的命令，one，该命令的执行情况，解析结果直接写入
+ */
+func (d *Device) parseCmdResult(cmd string, one *OneCMDRes) {
+	var (
+		res []map[string]interface{}
+		err error
+	)
+	spec, ok := d.CmdTextFsm[cmd]
+	switch {
+	case ok && spec.Content != "":
+		res, err = TextFsmParseViaContent(one.RES, spec.Content)
+	case ok && spec.Template != "":
+		one.Template = spec.Template
+		res, err = TextFsmParseViaTemplateFile(d.Brand, one.RES, spec.Template)
+	case d.AutoTextFsm:
+		templateName, found := LookupTemplate(d.Brand, cmd)
+		if !found {
+			return
+		}
+		one.Template = templateName
+		res, err = TextFsmParseViaTemplateFile(d.Brand, one.RES, templateName)
+	default:
+		return
+	}
+	if err != nil {
+		LogDebug("TextFsm解析失败%v,命令:%s,模板:%s,IP:%s", err, cmd, one.Template, d.IP)
+		return
+	}
+	one.TextFsmResults = res
+}
+
 /**
  * 对交换机执行的结果进行过滤
  * 1、处理每行回显，除去空白，tab
- * 2、将Last configuration wasXXXX统一替换成Last configuration updateed or saved
+ * 2、按归一化规则处理每行回显（如将Last configuration wasXXXX统一替换成Last configuration updateed or saved）
  * 3、截取第一个出现命令之后的内容
- * @paramn result:返回的执行结果（可能包含脏数据）, firstCmd:执行的第一条指令
+ * @paramn result:返回的执行结果（可能包含脏数据）, firstCmd:执行的第一条指令, rules:归一化规则
  * @return 过滤后的执行结果
  */
-func filterResult(result, firstCmd string) string {
+func filterResult(result, firstCmd string, rules []NormalizeRule) string {
 	filteredResult := ""
 	//将所有PROMPT替换成空字符串
 	//re := regexp.MustCompile(PROMPT)
@@ -286,9 +370,10 @@ func filterResult(result, firstCmd string) string {
 		resultItem = strings.Replace(resultItem, " \b", "", -1)
 		//去除行尾空白字符
 		resultItem = strings.TrimRight(resultItem, " ")
-		//判断是否包含Last configuration was，若包含则将该行整个都替换掉，以屏蔽保存时间不一样导致的每次配置比对都有差异
-		if strings.Contains(resultItem, "Last configuration was") {
-			resultItem = strings.Replace(resultItem, resultItem, "Last configuration updateed or saved", -1)
+		//按归一化规则替换或丢弃该行，以屏蔽保存时间、版本号等不一样导致的每次配置比对都有差异
+		resultItem, ok := NormalizeLine(resultItem, rules)
+		if !ok {
+			continue
 		}
 		//拼接
 		filteredResult += resultItem + "\n"
@@ -338,13 +423,8 @@ func TextFsmParseViaTemplateFile(brand string, waitToParse string, templateName
 	if brand == "" {
 		return nil, errors.New("brand为空，无法进行textfsm解析")
 	}
-	content, err := os.ReadFile(fmt.Sprintf("common/textfsm/%s/%s", brand, templateName))
-	if err != nil {
-		return nil, err
-	}
-	template := string(content)
-	fsm := gotextfsm.TextFSM{}
-	err = fsm.ParseString(template)
+	//模板随包内置，可通过SetTemplateOverrideDir覆盖，不依赖当前工作目录；编译结果会被缓存
+	fsm, err := compiledTemplate(brand, templateName)
 	if err != nil {
 		fmt.Printf("Error while parsing template '%s'\n", err.Error())
 		return nil, err
@@ -368,8 +448,7 @@ func TextFsmParseViaTemplateFile(brand string, waitToParse string, templateName
  * @author gulilin 2023/11/9 11:08
  */
 func TextFsmParseViaContent(waitToParse string, textFmsContent string) ([]map[string]interface{}, error) {
-	fsm := gotextfsm.TextFSM{}
-	err := fsm.ParseString(textFmsContent)
+	fsm, err := compiledContent(textFmsContent)
 	if err != nil {
 		fmt.Printf("Error while parsing template '%s'\n", err.Error())
 		return nil, err
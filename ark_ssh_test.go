@@ -2,6 +2,9 @@ package arkssh
 
 import (
 	"fmt"
+	"os"
+	"reflect"
+	"strings"
 	"testing"
 )
 
@@ -25,3 +28,116 @@ func TestBulkRunCmd(t *testing.T) {
 			devs[i].IP, devs[i].SendStatus, devs[i].RawResult, devs[i].MapResult)
 	}
 }
+
+// 将脚本会话放入会话缓存，设备的采集方法会直接使用该会话，测试结束后移除
+func cacheScriptedSession(t *testing.T, d *Device, s *SSHSession) {
+	if d.Port == "" {
+		d.Port = "22"
+	}
+	sessionKey := d.Username + "_" + d.Password + "_" + d.IP + ":" + d.Port
+	sessionManager.sessionCacheLocker.Lock()
+	sessionManager.sessionCache[sessionKey] = s
+	sessionManager.sessionCacheLocker.Unlock()
+	t.Cleanup(func() {
+		sessionManager.sessionCacheLocker.Lock()
+		delete(sessionManager.sessionCache, sessionKey)
+		sessionManager.sessionCacheLocker.Unlock()
+	})
+}
+
+// 加载华为mac_tables模板的第一个用例，用于检查按命令解析的结果
+func loadMacTableFixture(t *testing.T) TextFsmFixture {
+	fixtures, err := LoadTextFsmFixtures(os.DirFS(fixtureRoot + "/huawei/mac_tables"))
+	if err != nil || len(fixtures) == 0 {
+		t.Fatalf("load fixtures:%v", err)
+	}
+	return fixtures[0]
+}
+
+// 将解析结果与用例的期望结果按json转换后比较
+func assertFixtureRecords(t *testing.T, got []map[string]interface{}, fixture TextFsmFixture) {
+	t.Helper()
+	normalized, _ := normalizeFixtureRecords(got)
+	expected, _ := normalizeFixtureRecords(fixture.Expected)
+	if !reflect.DeepEqual(normalized, expected) {
+		t.Fatalf("records got %v, want %v", normalized, expected)
+	}
+}
+
+func TestParseCmdResultCmdTextFsm(t *testing.T) {
+	fixture := loadMacTableFixture(t)
+	content, err := ReadTemplate(HUAWEI, "mac_tables")
+	if err != nil {
+		t.Fatal(err)
+	}
+	cases := []struct {
+		name         string
+		cmd          string
+		auto         bool
+		cmdTextFsm   map[string]CmdTextFsm
+		wantTemplate string
+		wantParsed   bool
+	}{
+		{name: "模板索引", cmd: "dis mac-address", auto: true, wantTemplate: "mac_tables", wantParsed: true},
+		{name: "未开启模板索引", cmd: "display mac-address"},
+		{name: "模板索引未命中", cmd: "display clock", auto: true},
+		{name: "指定模板名称", cmd: "display mac-address",
+			cmdTextFsm: map[string]CmdTextFsm{"display mac-address": {Template: "mac_tables"}}, wantTemplate: "mac_tables", wantParsed: true},
+		{name: "指定模板优先于模板索引", cmd: "display mac-address", auto: true,
+			cmdTextFsm: map[string]CmdTextFsm{"display mac-address": {Template: "no_such_template"}}, wantTemplate: "no_such_template"},
+		{name: "内联模板", cmd: "display mac-address",
+			cmdTextFsm: map[string]CmdTextFsm{"display mac-address": {Content: content}}, wantParsed: true},
+		{name: "内联模板优先于模板名称", cmd: "display mac-address",
+			cmdTextFsm: map[string]CmdTextFsm{"display mac-address": {Template: "no_such_template", Content: content}}, wantParsed: true},
+		{name: "只对指定的命令生效", cmd: "display mac-address vlan 10",
+			cmdTextFsm: map[string]CmdTextFsm{"display mac-address": {Content: content}}},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			d := &Device{Brand: HUAWEI, AutoTextFsm: c.auto, CmdTextFsm: c.cmdTextFsm}
+			one := OneCMDRes{RES: fixture.Raw}
+			d.parseCmdResult(c.cmd, &one)
+			if one.Template != c.wantTemplate {
+				t.Fatalf("template got %q, want %q", one.Template, c.wantTemplate)
+			}
+			if !c.wantParsed {
+				if one.TextFsmResults != nil {
+					t.Fatalf("should not parse, got %v", one.TextFsmResults)
+				}
+				return
+			}
+			assertFixtureRecords(t, one.TextFsmResults, fixture)
+		})
+	}
+}
+
+func TestRunCmdWithBrandMapResult(t *testing.T) {
+	fixture := loadMacTableFixture(t)
+	body := fixture.Raw[strings.Index(fixture.Raw, "\n")+1:]
+	s, _ := newScriptedSession(HUAWEI, func(cmd string) string {
+		if cmd == "display mac-address" {
+			return body
+		}
+		return "\r\n<S5700-01>"
+	})
+	d := &Device{IP: "192.0.2.10", Port: "22", Username: "admin", Password: "pass", Brand: HUAWEI, Timeout: 1,
+		Cmds:        []string{"display mac-address", "display clock"},
+		CmdTextFsm:  map[string]CmdTextFsm{"display mac-address": {Template: "mac_tables"}},
+		AutoTextFsm: true}
+	cacheScriptedSession(t, d, s)
+	if err := d.RunCmdWithBrand(1); err != nil {
+		t.Fatal(err)
+	}
+	//每条命令的解析结果按原始命令写入MapResult
+	if len(d.MapResult) != 2 {
+		t.Fatalf("MapResult got %v", d.MapResult)
+	}
+	mac := d.MapResult["display mac-address"]
+	if mac.Status != "success" || mac.Template != "mac_tables" {
+		t.Fatalf("display mac-address got %+v", mac)
+	}
+	assertFixtureRecords(t, mac.TextFsmResults, fixture)
+	if clock := d.MapResult["display clock"]; clock.Template != "" || clock.TextFsmResults != nil {
+		t.Fatalf("display clock got %+v", clock)
+	}
+}
@@ -0,0 +1,134 @@
+package arkssh
+
+import (
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// ARP表对应的textfsm模板名称
+const ARPTablesTemplate = "arp_tables"
+
+// ARP表项的类型
+const (
+	ARPTypeDynamic    = "dynamic"
+	ARPTypeStatic     = "static"
+	ARPTypeInterface  = "interface"
+	ARPTypeIncomplete = "incomplete"
+)
+
+// 三层vlan接口的名称，如Vlanif100、Vlan-interface100、Vlan100
+var vlanInterfaceRegexp = regexp.MustCompile(`(?i)^vlan(?:if|-interface)?(\d+)$`)
+
+/**
+ * 规范化后的ARP表项
+ * @attr IP:IP地址，MAC:小写冒号分隔的MAC地址（未解析完成时为空），Interface:规范化的接口名，Vlan:所属vlan（设备未显示时从vlan接口名推断），
+ *       Type:表项类型（dynamic/static/interface/incomplete），VRF:所属VPN实例/VRF，公网为空
+ */
+type ARPEntry struct {
+	IP        string `bson:"ip" json:"ip"`
+	MAC       string `bson:"mac,omitempty" json:"mac,omitempty"`
+	Interface string `bson:"interface,omitempty" json:"interface,omitempty"`
+	Vlan      int    `bson:"vlan,omitempty" json:"vlan,omitempty"`
+	Type      string `bson:"type,omitempty" json:"type,omitempty"`
+	VRF       string `bson:"vrf,omitempty" json:"vrf,omitempty"`
+}
+
+// arp_tables模板的一条记录，华三的vlan、接口可能为N/A，按字符串读取
+type arpRecord struct {
+	IP          string `textfsm:"ip"`
+	MAC         string `textfsm:"mac"`
+	Age         string `textfsm:"age"`
+	Type        string `textfsm:"type"`
+	Interface   string `textfsm:"interface"`
+	Vlan        string `textfsm:"vlan"`
+	VPNInstance string `textfsm:"vpn_instance"`
+}
+
+/**
+ * 外部调用的统一方法，获取设备的ARP表
+ * @return 规范化后的ARP表项和执行错误
+ */
+func (d *Device) GetARPTable() ([]ARPEntry, error) {
+	var entries []ARPEntry
+	err := d.withSession(func(s *SSHSession) error {
+		var err error
+		entries, err = s.GetARPTable(d.getterTimeout())
+		return err
+	})
+	if err != nil {
+		LogError("获取ARP表错误:%s,IP:%s", err.Error(), d.IP)
+		return nil, err
+	}
+	LogDebug("获取ARP表成功,IP:%s,表项数量:%d", d.IP, len(entries))
+	return entries, nil
+}
+
+/**
+ * 获取当前SSH到的设备的ARP表，执行的命令由Driver的GetterARPTable定义
+ * @param	timeout，读取单条命令回显的超时时间（秒）
+ * @return 规范化后的ARP表项和执行错误
+ */
+func (s *SSHSession) GetARPTable(timeout int) ([]ARPEntry, error) {
+	results, err := s.runGetter(GetterARPTable, timeout)
+	if err != nil {
+		return nil, err
+	}
+	return BuildARPTable(s.brand, results[ARPTablesTemplate])
+}
+
+/**
+ * 将arp_tables模板的解析结果转换为规范化的ARP表项
+ * @param	brand，品牌名称，records，arp_tables模板的解析结果
+ * @return  ARP表项和转换错误
+ */
+func BuildARPTable(brand string, records []map[string]interface{}) ([]ARPEntry, error) {
+	rows, _, err := ParseInto[arpRecord](records)
+	if err != nil {
+		return nil, err
+	}
+	entries := make([]ARPEntry, 0, len(rows))
+	for _, row := range rows {
+		entry := ARPEntry{IP: row.IP, VRF: row.VPNInstance, Type: normalizeARPType(brand, row.Type, row.Age)}
+		if mac, err := NormalizeMAC(row.MAC); err == nil {
+			entry.MAC = mac
+		} else if strings.EqualFold(row.MAC, ARPTypeIncomplete) {
+			entry.Type = ARPTypeIncomplete
+		} else {
+			return nil, err
+		}
+		if row.Interface != "N/A" {
+			entry.Interface = CanonicalPortName(brand, row.Interface)
+		}
+		if vlan, err := strconv.Atoi(row.Vlan); err == nil {
+			entry.Vlan = vlan
+		} else if match := vlanInterfaceRegexp.FindStringSubmatch(entry.Interface); match != nil {
+			entry.Vlan, _ = strconv.Atoi(match[1])
+		}
+		entries = append(entries, entry)
+	}
+	return entries, nil
+}
+
+// 统一表项类型：华为的I为接口地址、D-x为动态，华三的I为无效表项，思科没有类型列，老化时间为-的是接口地址
+func normalizeARPType(brand, raw, age string) string {
+	if brand == CISCO {
+		if age == "-" {
+			return ARPTypeInterface
+		}
+		return ARPTypeDynamic
+	}
+	raw = strings.TrimSpace(raw)
+	switch {
+	case raw == "":
+		return ""
+	case strings.HasPrefix(raw, "D"):
+		return ARPTypeDynamic
+	case strings.HasPrefix(raw, "S"):
+		return ARPTypeStatic
+	case strings.HasPrefix(raw, "I") && brand == HUAWEI:
+		return ARPTypeInterface
+	default:
+		return strings.ToLower(raw)
+	}
+}
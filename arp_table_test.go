@@ -0,0 +1,39 @@
+package arkssh
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestBuildARPTable(t *testing.T) {
+	cases := []struct {
+		brand, fixture string
+		want           []ARPEntry
+	}{
+		{HUAWEI, "all", []ARPEntry{
+			{IP: "10.1.1.1", MAC: "0c:45:ba:12:34:00", Interface: "Vlanif100", Vlan: 100, Type: ARPTypeInterface},
+			{IP: "10.1.1.10", MAC: "00:50:56:94:1a:2b", Interface: "GigabitEthernet1/0/10", Vlan: 100, Type: ARPTypeDynamic},
+			{IP: "10.1.1.11", MAC: "00:50:56:94:1a:2c", Interface: "Eth-Trunk1", Vlan: 200, Type: ARPTypeDynamic, VRF: "vpn1"},
+			{IP: "10.1.1.254", MAC: "00:e0:fc:00:58:00", Interface: "Vlanif100", Vlan: 100, Type: ARPTypeStatic},
+		}},
+		{H3C, "arp", []ARPEntry{
+			{IP: "10.1.1.10", MAC: "00:50:56:94:1a:2b", Interface: "GigabitEthernet1/0/10", Vlan: 100, Type: ARPTypeDynamic},
+			{IP: "10.1.1.11", MAC: "00:50:56:94:1a:2c", Interface: "Bridge-Aggregation1", Vlan: 100, Type: ARPTypeDynamic},
+			{IP: "10.1.1.254", MAC: "00:e0:fc:00:58:00", Type: ARPTypeStatic},
+		}},
+		{CISCO, "ios", []ARPEntry{
+			{IP: "10.1.1.1", MAC: "0c:45:ba:12:34:00", Interface: "Vlan100", Vlan: 100, Type: ARPTypeInterface},
+			{IP: "10.1.1.10", MAC: "00:50:56:94:1a:2b", Interface: "Vlan100", Vlan: 100, Type: ARPTypeDynamic},
+			{IP: "10.1.1.11", Type: ARPTypeIncomplete},
+		}},
+	}
+	for _, c := range cases {
+		got, err := BuildARPTable(c.brand, fixtureRecords(t, c.brand, ARPTablesTemplate, c.fixture))
+		if err != nil {
+			t.Fatalf("%s BuildARPTable err:%v", c.brand, err)
+		}
+		if !reflect.DeepEqual(got, c.want) {
+			t.Fatalf("%s got %+v", c.brand, got)
+		}
+	}
+}
@@ -0,0 +1,327 @@
+package arkssh
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// 配置的类型
+const (
+	ConfigRunning = "running"
+	ConfigStartup = "startup"
+)
+
+const (
+	// 配置文件名中的时间格式，精确到毫秒，按文件名排序即按时间排序
+	configTimeLayout = "20060102-150405.000"
+	configFileExt    = ".cfg"
+)
+
+/**
+ * 本地的配置版本库，目录结构为<Dir>/<设备IP>/<配置类型>/<时间>.cfg
+ * @attr Dir:根目录，Keep:每台设备每种配置最多保留的版本数，0表示不限制
+ */
+type ConfigStore struct {
+	Dir  string
+	Keep int
+}
+
+/**
+ * 配置的一个版本
+ * @attr IP:设备IP，Kind:配置类型（running/startup），Time:采集时间，Path:文件路径
+ */
+type ConfigVersion struct {
+	IP   string    `bson:"ip" json:"ip"`
+	Kind string    `bson:"kind" json:"kind"`
+	Time time.Time `bson:"time" json:"time"`
+	Path string    `bson:"path" json:"path"`
+}
+
+/**
+ * 单台设备单种配置的备份结果
+ * @attr IP:设备IP，Kind:配置类型，Changed:与上一个版本相比是否有变化（首次备份也为true），Version:本次保存的版本，未变化时为最新版本，
+ *       Added:新增的行数，Removed:删除的行数，Error:采集或保存失败的原因
+ */
+type BackupResult struct {
+	IP      string         `bson:"ip" json:"ip"`
+	Kind    string         `bson:"kind" json:"kind"`
+	Changed bool           `bson:"changed" json:"changed"`
+	Version *ConfigVersion `bson:"version,omitempty" json:"version,omitempty"`
+	Added   int            `bson:"added,omitempty" json:"added,omitempty"`
+	Removed int            `bson:"removed,omitempty" json:"removed,omitempty"`
+	Error   string         `bson:"error,omitempty" json:"error,omitempty"`
+}
+
+/**
+ * 创建配置版本库，目录不存在时自动创建
+ * @param	dir，根目录，keep，每台设备每种配置最多保留的版本数，0表示不限制
+ * @return  配置版本库和创建目录的错误
+ */
+func NewConfigStore(dir string, keep int) (*ConfigStore, error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, err
+	}
+	return &ConfigStore{Dir: dir, Keep: keep}, nil
+}
+
+// 设备的目录名，IPv6地址中的冒号替换为下划线
+func (c *ConfigStore) kindDir(ip, kind string) string {
+	return filepath.Join(c.Dir, strings.ReplaceAll(ip, ":", "_"), kind)
+}
+
+/**
+ * 获取设备某种配置的所有版本
+ * @param	ip，设备IP，kind，配置类型
+ * @return  按时间从旧到新排列的版本，没有备份时为空
+ */
+func (c *ConfigStore) Versions(ip, kind string) ([]ConfigVersion, error) {
+	dir := c.kindDir(ip, kind)
+	files, err := os.ReadDir(dir)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	versions := make([]ConfigVersion, 0, len(files))
+	for _, file := range files {
+		name := file.Name()
+		if file.IsDir() || !strings.HasSuffix(name, configFileExt) {
+			continue
+		}
+		at, err := time.ParseInLocation(configTimeLayout, strings.TrimSuffix(name, configFileExt), time.Local)
+		if err != nil {
+			continue
+		}
+		versions = append(versions, ConfigVersion{IP: ip, Kind: kind, Time: at, Path: filepath.Join(dir, name)})
+	}
+	sort.Slice(versions, func(i, j int) bool { return versions[i].Time.Before(versions[j].Time) })
+	return versions, nil
+}
+
+/**
+ * 获取设备某种配置的最新版本
+ * @param	ip，设备IP，kind，配置类型
+ * @return  最新版本，没有备份时为nil
+ */
+func (c *ConfigStore) Latest(ip, kind string) (*ConfigVersion, error) {
+	versions, err := c.Versions(ip, kind)
+	if err != nil || len(versions) == 0 {
+		return nil, err
+	}
+	return &versions[len(versions)-1], nil
+}
+
+// Read 读取某个版本的配置内容
+func (c *ConfigStore) Read(version ConfigVersion) (string, error) {
+	data, err := os.ReadFile(version.Path)
+	return string(data), err
+}
+
+/**
+ * 保存一份配置，内容与最新版本相同时不保存
+ * @param	ip，设备IP，kind，配置类型，config，已归一化的配置内容，at，采集时间
+ * @return  备份结果（包含与上一个版本相比的增删行数）和保存错误
+ */
+func (c *ConfigStore) Save(ip, kind, config string, at time.Time) (BackupResult, error) {
+	result := BackupResult{IP: ip, Kind: kind}
+	latest, err := c.Latest(ip, kind)
+	if err != nil {
+		return result, err
+	}
+	previous := ""
+	if latest != nil {
+		if previous, err = c.Read(*latest); err != nil {
+			return result, err
+		}
+		if previous == config {
+			result.Version = latest
+			return result, nil
+		}
+	}
+	dir := c.kindDir(ip, kind)
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return result, err
+	}
+	version := ConfigVersion{IP: ip, Kind: kind, Time: at, Path: filepath.Join(dir, at.Format(configTimeLayout)+configFileExt)}
+	if err := os.WriteFile(version.Path, []byte(config), 0o644); err != nil {
+		return result, err
+	}
+	result.Changed, result.Version = true, &version
+	result.Added, result.Removed = countLineChanges(previous, config)
+	return result, c.prune(ip, kind)
+}
+
+// 删除超出保留数量的旧版本
+func (c *ConfigStore) prune(ip, kind string) error {
+	if c.Keep <= 0 {
+		return nil
+	}
+	versions, err := c.Versions(ip, kind)
+	if err != nil {
+		return err
+	}
+	for i := 0; i < len(versions)-c.Keep; i++ {
+		if err := os.Remove(versions[i].Path); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// 按行统计新增和删除的行数，不考虑行的顺序
+func countLineChanges(previous, current string) (added, removed int) {
+	counts := make(map[string]int)
+	for _, line := range splitConfigLines(previous) {
+		counts[line]++
+	}
+	for _, line := range splitConfigLines(current) {
+		if counts[line] > 0 {
+			counts[line]--
+			continue
+		}
+		added++
+	}
+	for _, n := range counts {
+		removed += n
+	}
+	return added, removed
+}
+
+func splitConfigLines(config string) []string {
+	if config == "" {
+		return nil
+	}
+	return strings.Split(strings.TrimRight(config, "\n"), "\n")
+}
+
+/**
+ * 外部调用的统一方法，获取设备归一化后的配置
+ * @param	kind，配置类型（ConfigRunning/ConfigStartup）
+ * @return  配置内容和执行错误
+ */
+func (d *Device) FetchConfig(kind string) (string, error) {
+	var config string
+	err := d.withSession(func(s *SSHSession) error {
+		var err error
+		config, err = s.FetchConfig(kind, d.getterTimeout(), d.NormalizeRules...)
+		return err
+	})
+	if err != nil {
+		LogError("获取配置错误:%s,IP:%s,kind:%s", err.Error(), d.IP, kind)
+		return "", err
+	}
+	return config, nil
+}
+
+/**
+ * 获取当前SSH到的设备的配置，执行的命令由Driver的RunningConfigCmd/StartupConfigCmd定义，回显按品牌的归一化规则处理，
+ * 并去掉回显中的命令行和提示符，换行统一为\n；回显不完整或为设备的错误信息（如没有已保存的配置、权限不足）时返回错误，避免保存半份或错误的配置
+ * @param	kind，配置类型，timeout，读取回显的超时时间（秒），extra，额外的归一化规则
+ * @return  配置内容和执行错误
+ */
+func (s *SSHSession) FetchConfig(kind string, timeout int, extra ...NormalizeRule) (string, error) {
+	brand := s.GetSSHBrand()
+	driver := s.Driver()
+	if driver == nil {
+		return "", errors.New("无法识别设备品牌，不能获取配置")
+	}
+	cmd := driver.RunningConfigCmd()
+	if kind == ConfigStartup {
+		cmd = driver.StartupConfigCmd()
+	}
+	if cmd == "" {
+		return "", fmt.Errorf("品牌%s不支持获取%s配置", brand, kind)
+	}
+	sendCmd := driver.FormatCmd(cmd)
+	s.WriteChannel(sendCmd)
+	output, ok := s.ReadChannelTiming(timeout)
+	if errLine, failed := MatchDriverError(driver, output); failed {
+		return "", fmt.Errorf("获取配置失败:%s,%s", sendCmd, errLine)
+	}
+	if !ok {
+		return "", fmt.Errorf("配置采集不完整:%s", sendCmd)
+	}
+	rules := append(GetNormalizeRules(brand), extra...)
+	return cleanConfigOutput(filterResult(output, sendCmd, rules), sendCmd), nil
+}
+
+// 去掉回显首行的命令和末行的提示符，换行统一为\n
+func cleanConfigOutput(output, cmd string) string {
+	lines := strings.Split(strings.ReplaceAll(output, "\r", ""), "\n")
+	if len(lines) > 0 && strings.HasSuffix(strings.TrimSpace(lines[0]), cmd) {
+		lines = lines[1:]
+	}
+	if n := len(lines); n > 0 && PromptHostname(lines[n-1]) != "" {
+		lines = lines[:n-1]
+	}
+	return strings.Trim(strings.Join(lines, "\n"), "\n") + "\n"
+}
+
+/**
+ * 批量备份配置，并发方式与BulkRunCmd相同（每台设备一个协程），同一台设备的多种配置顺序采集
+ * @param	devices，要备份的设备，store，配置版本库，kinds，配置类型，为空时只备份运行配置
+ * @return  按设备、配置类型顺序排列的备份结果；失败原因记录在结果的Error中，同时汇总为返回的错误
+ */
+func BackupConfigs(devices []Device, store *ConfigStore, kinds ...string) ([]BackupResult, error) {
+	if len(kinds) == 0 {
+		kinds = []string{ConfigRunning}
+	}
+	results := make([][]BackupResult, len(devices))
+	errs := make([]error, len(devices)*len(kinds))
+	wg := sync.WaitGroup{}
+	wg.Add(len(devices))
+	for i := range devices {
+		go func(i int, d *Device) {
+			defer wg.Done()
+			for j, kind := range kinds {
+				result := BackupResult{IP: d.IP, Kind: kind}
+				config, err := d.FetchConfig(kind)
+				if err == nil {
+					result, err = store.Save(d.IP, kind, config, time.Now())
+				}
+				if err != nil {
+					result.Error = err.Error()
+					errs[i*len(kinds)+j] = fmt.Errorf("IP:%s,kind:%s,err:%v", d.IP, kind, err)
+				}
+				results[i] = append(results[i], result)
+			}
+		}(i, &devices[i])
+	}
+	wg.Wait()
+	summary := make([]BackupResult, 0, len(devices)*len(kinds))
+	for _, items := range results {
+		summary = append(summary, items...)
+	}
+	return summary, errors.Join(errs...)
+}
+
+/**
+ * 将批量备份的结果汇总为文本，每个结果一行，如"10.1.1.1 running changed +3 -1"
+ * @param	results，备份结果
+ * @return  汇总文本
+ */
+func BackupSummary(results []BackupResult) string {
+	var sb strings.Builder
+	changed, failed := 0, 0
+	for _, result := range results {
+		switch {
+		case result.Error != "":
+			failed++
+			sb.WriteString(fmt.Sprintf("%s %s failed:%s\n", result.IP, result.Kind, result.Error))
+		case result.Changed:
+			changed++
+			sb.WriteString(fmt.Sprintf("%s %s changed +%d -%d\n", result.IP, result.Kind, result.Added, result.Removed))
+		default:
+			sb.WriteString(fmt.Sprintf("%s %s unchanged\n", result.IP, result.Kind))
+		}
+	}
+	sb.WriteString(fmt.Sprintf("total:%d,changed:%d,unchanged:%d,failed:%d\n", len(results), changed, len(results)-changed-failed, failed))
+	return sb.String()
+}
@@ -0,0 +1,87 @@
+package arkssh
+
+import (
+	"os"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestConfigStore(t *testing.T) {
+	store, err := NewConfigStore(t.TempDir(), 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	at := time.Date(2026, 10, 19, 9, 0, 0, 0, time.Local)
+	first := "sysname CE-01\ninterface GE1/0/1\n description to-CE-02\nreturn\n"
+	result, err := store.Save("10.1.1.1", ConfigRunning, first, at)
+	if err != nil || !result.Changed || result.Added != 4 || result.Removed != 0 {
+		t.Fatalf("first save got %+v, err:%v", result, err)
+	}
+	//内容不变时不保存新版本
+	result, err = store.Save("10.1.1.1", ConfigRunning, first, at.Add(time.Hour))
+	if err != nil || result.Changed || !result.Version.Time.Equal(at) {
+		t.Fatalf("unchanged save got %+v, err:%v", result, err)
+	}
+	second := strings.Replace(first, "to-CE-02", "to-CE-03", 1)
+	result, err = store.Save("10.1.1.1", ConfigRunning, second, at.Add(2*time.Hour))
+	if err != nil || !result.Changed || result.Added != 1 || result.Removed != 1 {
+		t.Fatalf("changed save got %+v, err:%v", result, err)
+	}
+	if _, err := store.Save("10.1.1.1", ConfigRunning, first, at.Add(3*time.Hour)); err != nil {
+		t.Fatal(err)
+	}
+	//只保留最近的2个版本
+	versions, err := store.Versions("10.1.1.1", ConfigRunning)
+	if err != nil || len(versions) != 2 || !versions[0].Time.Equal(at.Add(2*time.Hour)) {
+		t.Fatalf("versions got %+v, err:%v", versions, err)
+	}
+	if content, _ := store.Read(versions[1]); content != first {
+		t.Fatalf("latest content got %q", content)
+	}
+	if latest, err := store.Latest("10.1.1.2", ConfigStartup); latest != nil || err != nil {
+		t.Fatalf("no backup should return nil, got %+v, err:%v", latest, err)
+	}
+	if _, err := os.Stat(versions[0].Path); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestCleanConfigOutput(t *testing.T) {
+	output := filterResult("display current-configuration\r\n#\r\n!Software Version V200R005C20SPC800\r\nsysname CE-01\r\n#\r\nreturn\r\n<CE-01>", "display current-configuration", GetNormalizeRules(HUAWEI))
+	if got := cleanConfigOutput(output, "display current-configuration"); got != "#\nsysname CE-01\n#\nreturn\n" {
+		t.Fatalf("got %q", got)
+	}
+}
+
+func TestFetchConfigDeviceError(t *testing.T) {
+	//设备返回错误信息时不能当作配置保存
+	cases := []struct {
+		brand, kind, reply string
+	}{
+		{HUAWEI, ConfigStartup, "Error: The specified configuration file does not exist.\r\n<SW-01>"},
+		{CISCO, ConfigRunning, "                ^\r\n% Invalid input detected at '^' marker.\r\nSW-R1#"},
+	}
+	for _, c := range cases {
+		s, _ := newScriptedSession(c.brand, func(string) string { return c.reply })
+		if config, err := s.FetchConfig(c.kind, 1); err == nil || config != "" {
+			t.Fatalf("%s %s got %q, err:%v", c.brand, c.kind, config, err)
+		}
+	}
+	s, _ := newScriptedSession(HUAWEI, func(string) string { return "#\r\nsysname SW-01\r\n#\r\nreturn\r\n<SW-01>" })
+	if config, err := s.FetchConfig(ConfigRunning, 1); err != nil || config != "#\nsysname SW-01\n#\nreturn\n" {
+		t.Fatalf("running config got %q, err:%v", config, err)
+	}
+}
+
+func TestBackupSummary(t *testing.T) {
+	summary := BackupSummary([]BackupResult{
+		{IP: "10.1.1.1", Kind: ConfigRunning, Changed: true, Added: 3, Removed: 1},
+		{IP: "10.1.1.2", Kind: ConfigRunning},
+		{IP: "10.1.1.3", Kind: ConfigRunning, Error: "密码错误"},
+	})
+	want := "10.1.1.1 running changed +3 -1\n10.1.1.2 running unchanged\n10.1.1.3 running failed:密码错误\ntotal:3,changed:1,unchanged:1,failed:1\n"
+	if summary != want {
+		t.Fatalf("got %q", summary)
+	}
+}
@@ -0,0 +1,475 @@
+package arkssh
+
+import (
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// 配置块的比对状态
+const (
+	DiffAdded    = "added"
+	DiffRemoved  = "removed"
+	DiffModified = "modified"
+)
+
+var (
+	// 配置的结束行，不属于任何配置块
+	configEndLines = map[string]bool{"return": true, "end": true}
+	// 按顺序匹配的配置块（ACL、route-map等），块内的行按顺序比对，调整顺序也是差异
+	orderedSectionRegexp = regexp.MustCompile(`^(ip(v6)? access-list|acl|route-map|route-policy|ip prefix-list|ip ip-prefix)(\s|$)`)
+	// 逐行配置、按顺序匹配的列表（编号ACL、prefix-list等），同一列表的行按顺序比对，匹配到的部分为列表名称
+	orderedLineRegexp = regexp.MustCompile(`^(access-list \S+|ip(v6)? prefix-list \S+|ip ip-prefix \S+|ip as-path access-list \S+|ip community-list (standard |expanded )?\S+)`)
+	// 只有一个取值、取值中可能不含数字的命令，关键字为命令本身
+	singleValueCommands = map[string]bool{"description": true, "sysname": true, "hostname": true, "alias": true, "remark": true}
+)
+
+/**
+ * 配置树的节点，根节点的Line为空
+ * @attr Line:去掉缩进的配置行，Children:缩进更深的下级配置
+ */
+type ConfigNode struct {
+	Line     string        `bson:"line,omitempty" json:"line,omitempty"`
+	Children []*ConfigNode `bson:"children,omitempty" json:"children,omitempty"`
+}
+
+/**
+ * 配置块的比对结果，只包含有变化的内容
+ * @attr Section:配置块的首行（如interface GigabitEthernet1/0/1），根为空，Status:added/removed/modified，
+ *       Added:新增的行，Removed:删除的行，Changed:修改的行，Children:有变化的下级配置块
+ */
+type ConfigDiff struct {
+	Section  string         `bson:"section,omitempty" json:"section,omitempty"`
+	Status   string         `bson:"status" json:"status"`
+	Added    []string       `bson:"added,omitempty" json:"added,omitempty"`
+	Removed  []string       `bson:"removed,omitempty" json:"removed,omitempty"`
+	Changed  []ConfigChange `bson:"changed,omitempty" json:"changed,omitempty"`
+	Children []*ConfigDiff  `bson:"children,omitempty" json:"children,omitempty"`
+}
+
+/**
+ * 配置块中被修改的一行
+ * @attr From:原来的行，To:修改后的行
+ */
+type ConfigChange struct {
+	From string `bson:"from" json:"from"`
+	To   string `bson:"to" json:"to"`
+}
+
+/**
+ * 按缩进解析VRP、Comware、IOS风格的配置为配置树，忽略#、!分隔行和注释、空行以及结尾的return/end
+ * @param	text，配置文本
+ * @return  配置树的根节点
+ */
+func ParseConfigTree(text string) *ConfigNode {
+	type level struct {
+		indent int
+		node   *ConfigNode
+	}
+	root := &ConfigNode{}
+	stack := []level{{indent: -1, node: root}}
+	for _, raw := range strings.Split(strings.ReplaceAll(text, "\r", ""), "\n") {
+		line := strings.TrimRight(raw, " \t")
+		trimmed := strings.TrimLeft(line, " ")
+		if trimmed == "" || strings.HasPrefix(trimmed, "#") || strings.HasPrefix(trimmed, "!") {
+			continue
+		}
+		indent := len(line) - len(trimmed)
+		if indent == 0 && configEndLines[trimmed] {
+			continue
+		}
+		for len(stack) > 1 && stack[len(stack)-1].indent >= indent {
+			stack = stack[:len(stack)-1]
+		}
+		node := &ConfigNode{Line: trimmed}
+		parent := stack[len(stack)-1].node
+		parent.Children = append(parent.Children, node)
+		stack = append(stack, level{indent: indent, node: node})
+	}
+	return root
+}
+
+/**
+ * 按配置块比对两份配置，块的顺序和块内行的顺序变化不算差异，ACL、route-map、prefix-list等按顺序匹配的配置除外，配置先按品牌的归一化规则处理
+ * @param	brand，品牌名称，oldText，原配置，newText，新配置
+ * @return  比对结果，没有差异时Empty()为true
+ */
+func DiffConfig(brand, oldText, newText string) *ConfigDiff {
+	oldTree := ParseConfigTree(NormalizeConfig(brand, oldText))
+	newTree := ParseConfigTree(NormalizeConfig(brand, newText))
+	diff := diffConfigNodes(oldTree.Children, newTree.Children)
+	if diff == nil {
+		return &ConfigDiff{Status: DiffModified}
+	}
+	return diff
+}
+
+// 比对同一配置块下的两组子节点，没有差异时返回nil
+func diffConfigNodes(oldNodes, newNodes []*ConfigNode) *ConfigDiff {
+	oldNodes, oldLists := splitOrderedLines(oldNodes)
+	newNodes, newLists := splitOrderedLines(newNodes)
+	oldIndex, oldOrder := indexConfigNodes(oldNodes)
+	newIndex, newOrder := indexConfigNodes(newNodes)
+	diff := &ConfigDiff{Status: DiffModified}
+	var added, removed []string
+	for _, name := range orderedListNames(oldLists, newLists) {
+		listAdded, listRemoved := diffOrderedLines(oldLists[name], newLists[name])
+		added, removed = append(added, listAdded...), append(removed, listRemoved...)
+	}
+	for _, line := range newOrder {
+		newNode, oldNode := newIndex[line], oldIndex[line]
+		switch {
+		case oldNode == nil && len(newNode.Children) == 0:
+			added = append(added, line)
+		case oldNode == nil:
+			diff.Children = append(diff.Children, wholeConfigSection(newNode, DiffAdded))
+		case len(oldNode.Children) > 0 && len(newNode.Children) == 0:
+			diff.Children = append(diff.Children, wholeConfigSection(oldNode, DiffRemoved))
+			added = append(added, line)
+		default:
+			if child := diffSectionChildren(line, oldNode.Children, newNode.Children); child != nil {
+				child.Section = line
+				diff.Children = append(diff.Children, child)
+			}
+		}
+	}
+	for _, line := range oldOrder {
+		if newIndex[line] != nil {
+			continue
+		}
+		if oldNode := oldIndex[line]; len(oldNode.Children) > 0 {
+			diff.Children = append(diff.Children, wholeConfigSection(oldNode, DiffRemoved))
+		} else {
+			removed = append(removed, line)
+		}
+	}
+	diff.Added, diff.Removed, diff.Changed = pairConfigChanges(added, removed)
+	if len(diff.Added) == 0 && len(diff.Removed) == 0 && len(diff.Changed) == 0 && len(diff.Children) == 0 {
+		return nil
+	}
+	return diff
+}
+
+// 比对配置块的下级配置，按顺序匹配的配置块按顺序比对
+func diffSectionChildren(section string, oldNodes, newNodes []*ConfigNode) *ConfigDiff {
+	if orderedSectionRegexp.MatchString(section) {
+		return diffOrderedConfigNodes(oldNodes, newNodes)
+	}
+	return diffConfigNodes(oldNodes, newNodes)
+}
+
+// 按顺序比对两组子节点：不在最长公共子序列中的行为删除或新增，调整顺序的行同时出现在删除和新增中
+func diffOrderedConfigNodes(oldNodes, newNodes []*ConfigNode) *ConfigDiff {
+	diff := &ConfigDiff{Status: DiffModified}
+	matches := lcsMatches(configNodeLines(oldNodes), configNodeLines(newNodes))
+	matchedOld, matchedNew := make(map[int]bool, len(matches)), make(map[int]bool, len(matches))
+	for _, match := range matches {
+		matchedOld[match[0]], matchedNew[match[1]] = true, true
+		oldNode, newNode := oldNodes[match[0]], newNodes[match[1]]
+		if child := diffConfigNodes(oldNode.Children, newNode.Children); child != nil {
+			child.Section = newNode.Line
+			diff.Children = append(diff.Children, child)
+		}
+	}
+	var added, removed []string
+	for i, node := range oldNodes {
+		switch {
+		case matchedOld[i]:
+		case len(node.Children) > 0:
+			diff.Children = append(diff.Children, wholeConfigSection(node, DiffRemoved))
+		default:
+			removed = append(removed, node.Line)
+		}
+	}
+	for i, node := range newNodes {
+		switch {
+		case matchedNew[i]:
+		case len(node.Children) > 0:
+			diff.Children = append(diff.Children, wholeConfigSection(node, DiffAdded))
+		default:
+			added = append(added, node.Line)
+		}
+	}
+	diff.Added, diff.Removed, diff.Changed = pairConfigChanges(added, removed)
+	if diff.Empty() {
+		return nil
+	}
+	return diff
+}
+
+// 按顺序比对同一列表的行，返回新增和删除的行
+func diffOrderedLines(oldLines, newLines []string) ([]string, []string) {
+	matches := lcsMatches(oldLines, newLines)
+	matchedOld, matchedNew := make(map[int]bool, len(matches)), make(map[int]bool, len(matches))
+	for _, match := range matches {
+		matchedOld[match[0]], matchedNew[match[1]] = true, true
+	}
+	var added, removed []string
+	for i, line := range oldLines {
+		if !matchedOld[i] {
+			removed = append(removed, line)
+		}
+	}
+	for i, line := range newLines {
+		if !matchedNew[i] {
+			added = append(added, line)
+		}
+	}
+	return added, removed
+}
+
+// 求两组行的最长公共子序列，返回相互对应的下标（旧、新）
+func lcsMatches(oldLines, newLines []string) [][2]int {
+	n, m := len(oldLines), len(newLines)
+	lengths := make([][]int, n+1)
+	for i := range lengths {
+		lengths[i] = make([]int, m+1)
+	}
+	for i := n - 1; i >= 0; i-- {
+		for j := m - 1; j >= 0; j-- {
+			switch {
+			case oldLines[i] == newLines[j]:
+				lengths[i][j] = lengths[i+1][j+1] + 1
+			case lengths[i+1][j] >= lengths[i][j+1]:
+				lengths[i][j] = lengths[i+1][j]
+			default:
+				lengths[i][j] = lengths[i][j+1]
+			}
+		}
+	}
+	matches := make([][2]int, 0, lengths[0][0])
+	for i, j := 0, 0; i < n && j < m; {
+		switch {
+		case oldLines[i] == newLines[j]:
+			matches = append(matches, [2]int{i, j})
+			i, j = i+1, j+1
+		case lengths[i+1][j] >= lengths[i][j+1]:
+			i++
+		default:
+			j++
+		}
+	}
+	return matches
+}
+
+func configNodeLines(nodes []*ConfigNode) []string {
+	lines := make([]string, 0, len(nodes))
+	for _, node := range nodes {
+		lines = append(lines, node.Line)
+	}
+	return lines
+}
+
+// 取出按顺序匹配的逐行配置（没有下级配置），按列表名称分组，其余节点原样返回
+func splitOrderedLines(nodes []*ConfigNode) ([]*ConfigNode, map[string][]string) {
+	rest := make([]*ConfigNode, 0, len(nodes))
+	lists := make(map[string][]string)
+	for _, node := range nodes {
+		name := orderedLineRegexp.FindString(node.Line)
+		if name == "" || len(node.Children) > 0 {
+			rest = append(rest, node)
+			continue
+		}
+		lists[name] = append(lists[name], node.Line)
+	}
+	return rest, lists
+}
+
+// 两份配置中出现的列表名称，按名称排序保证输出稳定
+func orderedListNames(oldLists, newLists map[string][]string) []string {
+	names := make([]string, 0, len(oldLists)+len(newLists))
+	for name := range oldLists {
+		names = append(names, name)
+	}
+	for name := range newLists {
+		if _, ok := oldLists[name]; !ok {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	return names
+}
+
+// 以行内容为key索引子节点，重复出现的配置块合并下级配置
+func indexConfigNodes(nodes []*ConfigNode) (map[string]*ConfigNode, []string) {
+	index := make(map[string]*ConfigNode, len(nodes))
+	order := make([]string, 0, len(nodes))
+	for _, node := range nodes {
+		if existing, ok := index[node.Line]; ok {
+			existing.Children = append(existing.Children, node.Children...)
+			continue
+		}
+		index[node.Line] = &ConfigNode{Line: node.Line, Children: node.Children}
+		order = append(order, node.Line)
+	}
+	return index, order
+}
+
+// 整块新增或删除的配置块，下级配置按层级缩进展开
+func wholeConfigSection(node *ConfigNode, status string) *ConfigDiff {
+	diff := &ConfigDiff{Section: node.Line, Status: status}
+	var walk func(children []*ConfigNode, depth int)
+	walk = func(children []*ConfigNode, depth int) {
+		for _, child := range children {
+			line := strings.Repeat(" ", depth) + child.Line
+			if status == DiffAdded {
+				diff.Added = append(diff.Added, line)
+			} else {
+				diff.Removed = append(diff.Removed, line)
+			}
+			walk(child.Children, depth+1)
+		}
+	}
+	walk(node.Children, 0)
+	return diff
+}
+
+// 同一配置块中，关键字相同且只各出现一次的删除行和新增行视为修改（如description、ip address），内容相同（调整了顺序）时不合并
+func pairConfigChanges(added, removed []string) ([]string, []string, []ConfigChange) {
+	addedKeys, removedKeys := make(map[string]int), make(map[string]int)
+	addedByKey := make(map[string]string)
+	for _, line := range added {
+		key := configLineKey(line)
+		addedKeys[key]++
+		addedByKey[key] = line
+	}
+	for _, line := range removed {
+		removedKeys[configLineKey(line)]++
+	}
+	removedByKey := make(map[string]string)
+	keptRemoved := make([]string, 0, len(removed))
+	for _, line := range removed {
+		key := configLineKey(line)
+		if addedKeys[key] == 1 && removedKeys[key] == 1 && addedByKey[key] != line {
+			removedByKey[key] = line
+			continue
+		}
+		keptRemoved = append(keptRemoved, line)
+	}
+	keptAdded := make([]string, 0, len(added))
+	var changed []ConfigChange
+	for _, line := range added {
+		if from, ok := removedByKey[configLineKey(line)]; ok {
+			changed = append(changed, ConfigChange{From: from, To: line})
+			continue
+		}
+		keptAdded = append(keptAdded, line)
+	}
+	if len(keptAdded) == 0 {
+		keptAdded = nil
+	}
+	if len(keptRemoved) == 0 {
+		keptRemoved = nil
+	}
+	return keptAdded, keptRemoved, changed
+}
+
+// 配置行的关键字：第一个取值（数字、地址、接口等）之前的单词，如ip ospf cost、ip helper-address，以取值开头时（如ACL的序号）为该取值；
+// description等只有一个取值的命令为命令本身；undo/no开头时带上undo/no
+func configLineKey(line string) string {
+	fields := strings.Fields(line)
+	prefix := ""
+	if len(fields) > 1 && (fields[0] == "undo" || fields[0] == "no") {
+		prefix, fields = fields[0]+" ", fields[1:]
+	}
+	if len(fields) == 0 {
+		return prefix
+	}
+	if singleValueCommands[fields[0]] {
+		return prefix + fields[0]
+	}
+	keys := make([]string, 0, len(fields))
+	for _, field := range fields {
+		if isConfigValue(field) {
+			break
+		}
+		keys = append(keys, field)
+	}
+	if len(keys) == 0 {
+		keys = fields[:1]
+	}
+	return prefix + strings.Join(keys, " ")
+}
+
+// 判断配置行中的单词是否为取值：以数字或引号开头，或为带数字的地址、接口（如10.1.1.1、GE1/0/1、fe80::1）
+func isConfigValue(field string) bool {
+	if field[0] >= '0' && field[0] <= '9' || field[0] == '"' {
+		return true
+	}
+	return strings.ContainsAny(field, "./:") && strings.ContainsAny(field, "0123456789")
+}
+
+// Empty 两份配置是否没有差异
+func (d *ConfigDiff) Empty() bool {
+	return len(d.Added) == 0 && len(d.Removed) == 0 && len(d.Changed) == 0 && len(d.Children) == 0
+}
+
+/**
+ * 以类似unified diff的格式输出：配置块的首行作为上下文（以空格开头），删除的行以-开头，新增的行以+开头，修改的行输出为一对-/+
+ * @return  比对结果文本，没有差异时为空
+ */
+func (d *ConfigDiff) Text() string {
+	var sb strings.Builder
+	d.writeText(&sb, 0)
+	return sb.String()
+}
+
+func (d *ConfigDiff) writeText(sb *strings.Builder, depth int) {
+	indent := strings.Repeat(" ", depth)
+	childIndent := indent
+	if d.Section != "" {
+		mark := " "
+		switch d.Status {
+		case DiffAdded:
+			mark = "+"
+		case DiffRemoved:
+			mark = "-"
+		}
+		sb.WriteString(fmt.Sprintf("%s%s%s\n", mark, indent, d.Section))
+		childIndent = indent + " "
+	}
+	for _, line := range d.Removed {
+		sb.WriteString(fmt.Sprintf("-%s%s\n", childIndent, line))
+	}
+	for _, change := range d.Changed {
+		sb.WriteString(fmt.Sprintf("-%s%s\n+%s%s\n", childIndent, change.From, childIndent, change.To))
+	}
+	for _, line := range d.Added {
+		sb.WriteString(fmt.Sprintf("+%s%s\n", childIndent, line))
+	}
+	for _, child := range d.Children {
+		if d.Section == "" {
+			child.writeText(sb, depth)
+		} else {
+			child.writeText(sb, depth+1)
+		}
+	}
+}
+
+// JSON 以JSON格式输出比对结果
+func (d *ConfigDiff) JSON() ([]byte, error) {
+	return json.MarshalIndent(d, "", "  ")
+}
+
+/**
+ * 比对配置版本库中某台设备最近的两个版本
+ * @param	brand，品牌名称，ip，设备IP，kind，配置类型
+ * @return  比对结果，版本少于两个时为nil
+ */
+func (c *ConfigStore) DiffLatest(brand, ip, kind string) (*ConfigDiff, error) {
+	versions, err := c.Versions(ip, kind)
+	if err != nil || len(versions) < 2 {
+		return nil, err
+	}
+	oldText, err := c.Read(versions[len(versions)-2])
+	if err != nil {
+		return nil, err
+	}
+	newText, err := c.Read(versions[len(versions)-1])
+	if err != nil {
+		return nil, err
+	}
+	return DiffConfig(brand, oldText, newText), nil
+}
@@ -0,0 +1,167 @@
+package arkssh
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+)
+
+const oldVRPConfig = `#
+sysname CE-01
+#
+interface GigabitEthernet1/0/1
+ description to-CE-02
+ port link-type trunk
+ port trunk allow-pass vlan 10 20
+#
+interface GigabitEthernet1/0/2
+ shutdown
+#
+acl number 3001
+ rule 5 permit ip source 10.1.1.0 0.0.0.255
+#
+bgp 100
+ peer 10.0.0.2 as-number 200
+ #
+ ipv4-family unicast
+  peer 10.0.0.2 enable
+#
+return
+`
+
+// 与oldVRPConfig相比：块的顺序调整，修改description，删除acl，新增接口，bgp的子块新增一行
+const newVRPConfig = `#
+sysname CE-01
+#
+interface GigabitEthernet1/0/2
+ shutdown
+#
+interface GigabitEthernet1/0/1
+ port trunk allow-pass vlan 10 20
+ description to-CE-03
+ port link-type trunk
+#
+interface GigabitEthernet1/0/3
+ description server-03
+#
+bgp 100
+ peer 10.0.0.2 as-number 200
+ #
+ ipv4-family unicast
+  peer 10.0.0.2 enable
+  network 10.1.1.0 255.255.255.0
+#
+return
+`
+
+func TestParseConfigTree(t *testing.T) {
+	root := ParseConfigTree(oldVRPConfig)
+	if len(root.Children) != 5 {
+		t.Fatalf("top level got %d nodes", len(root.Children))
+	}
+	bgp := root.Children[4]
+	if bgp.Line != "bgp 100" || len(bgp.Children) != 2 || bgp.Children[1].Children[0].Line != "peer 10.0.0.2 enable" {
+		t.Fatalf("bgp got %+v", bgp)
+	}
+	ios := ParseConfigTree("!\ninterface Gi0/1\n description up\n!\nend\n")
+	if len(ios.Children) != 1 || ios.Children[0].Children[0].Line != "description up" {
+		t.Fatalf("ios got %+v", ios.Children)
+	}
+}
+
+func TestDiffConfig(t *testing.T) {
+	if diff := DiffConfig("huawei", oldVRPConfig, oldVRPConfig); !diff.Empty() || diff.Text() != "" {
+		t.Fatalf("same config got %+v", diff)
+	}
+	diff := DiffConfig("huawei", oldVRPConfig, newVRPConfig)
+	want := ` interface GigabitEthernet1/0/1
+- description to-CE-02
++ description to-CE-03
++interface GigabitEthernet1/0/3
++ description server-03
+ bgp 100
+  ipv4-family unicast
++  network 10.1.1.0 255.255.255.0
+-acl number 3001
+- rule 5 permit ip source 10.1.1.0 0.0.0.255
+`
+	if got := diff.Text(); got != want {
+		t.Fatalf("text got:\n%s\nwant:\n%s", got, want)
+	}
+	data, err := diff.JSON()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var decoded ConfigDiff
+	if err := json.Unmarshal(data, &decoded); err != nil {
+		t.Fatal(err)
+	}
+	changed := decoded.Children[0].Changed
+	if len(changed) != 1 || changed[0].From != "description to-CE-02" || decoded.Children[3].Status != DiffRemoved {
+		t.Fatalf("json got %s", data)
+	}
+}
+
+func TestConfigLineKeyPairing(t *testing.T) {
+	//关键字相同的行有多条时无法确定对应关系，保持为新增和删除
+	added, removed, changed := pairConfigChanges(
+		[]string{"ip address 10.1.1.2 24", "vlan 30", "vlan 40"},
+		[]string{"ip address 10.1.1.1 24", "vlan 10"},
+	)
+	if len(changed) != 1 || changed[0].To != "ip address 10.1.1.2 24" || len(added) != 2 || len(removed) != 1 {
+		t.Fatalf("got added:%v removed:%v changed:%v", added, removed, changed)
+	}
+	if configLineKey("undo shutdown") != "undo shutdown" || configLineKey("no shutdown") != "no shutdown" {
+		t.Fatal("undo/no key mismatch")
+	}
+	//关键字取到第一个取值之前，不同命令不会合并为修改
+	added, removed, changed = pairConfigChanges([]string{"ip ospf cost 10"}, []string{"ip helper-address 10.1.1.1"})
+	if len(changed) != 0 || len(added) != 1 || len(removed) != 1 {
+		t.Fatalf("got added:%v removed:%v changed:%v", added, removed, changed)
+	}
+	if configLineKey("description to server") != "description" || configLineKey("10 permit ip any any") != "10" {
+		t.Fatal("description/sequence key mismatch")
+	}
+}
+
+func TestDiffConfigOrdered(t *testing.T) {
+	//ACL块内调整规则顺序是差异
+	oldACL := "ip access-list extended WEB\n permit tcp any any eq 80\n deny ip any any\n!\nend\n"
+	newACL := "ip access-list extended WEB\n deny ip any any\n permit tcp any any eq 80\n!\nend\n"
+	diff := DiffConfig("cisco", oldACL, newACL)
+	if diff.Empty() || len(diff.Children) != 1 || len(diff.Children[0].Changed) != 0 ||
+		len(diff.Children[0].Added) != 1 || len(diff.Children[0].Removed) != 1 {
+		t.Fatalf("acl got %+v", diff)
+	}
+	//普通配置块内的顺序仍不算差异
+	if diff := DiffConfig("cisco", "interface Gi0/1\n shutdown\n description up\n", "interface Gi0/1\n description up\n shutdown\n"); !diff.Empty() {
+		t.Fatalf("interface got %+v", diff)
+	}
+	//顶层的prefix-list按列表顺序比对
+	oldPL := "ip prefix-list PL permit 10.0.0.0/8\nip prefix-list PL deny 0.0.0.0/0 le 32\nhostname R1\n"
+	newPL := "hostname R1\nip prefix-list PL deny 0.0.0.0/0 le 32\nip prefix-list PL permit 10.0.0.0/8\n"
+	diff = DiffConfig("cisco", oldPL, newPL)
+	if diff.Empty() || len(diff.Added) != 1 || len(diff.Removed) != 1 || diff.Added[0] != diff.Removed[0] {
+		t.Fatalf("prefix-list got %+v", diff)
+	}
+}
+
+func TestConfigStoreDiffLatest(t *testing.T) {
+	store, err := NewConfigStore(t.TempDir(), 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if diff, err := store.DiffLatest("huawei", "10.1.1.1", ConfigRunning); diff != nil || err != nil {
+		t.Fatalf("empty store got %+v, err:%v", diff, err)
+	}
+	at := time.Date(2026, 10, 19, 10, 0, 0, 0, time.Local)
+	for i, config := range []string{oldVRPConfig, newVRPConfig} {
+		if _, err := store.Save("10.1.1.1", ConfigRunning, config, at.Add(time.Duration(i)*time.Hour)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	diff, err := store.DiffLatest("huawei", "10.1.1.1", ConfigRunning)
+	if err != nil || diff == nil || len(diff.Children) != 4 {
+		t.Fatalf("diff latest got %+v, err:%v", diff, err)
+	}
+}
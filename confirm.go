@@ -0,0 +1,235 @@
+package arkssh
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// 确认推送的状态
+const (
+	TxnPending  = "pending"  //配置已生效，等待确认，未确认时设备会自动恢复
+	TxnReverted = "reverted" //未能确认（无法重新登录、检查失败或已超过自动恢复时间），由设备的定时器自动恢复
+)
+
+// 确认命令送达设备需要时间，距自动恢复不足该时间时不再确认，避免确认与设备恢复同时发生
+const confirmMargin = 5 * time.Second
+
+/**
+ * 打开一个新的会话替换缓存中的会话，用于确认推送后设备仍可登录；旧会话可能因配置变更已不可用，替换成功后关闭
+ * @param	d，设备，brand，推送时识别到的品牌
+ * @return  新的会话和登录错误
+ */
+var reconnectDevice = func(d *Device, brand string) (*SSHSession, error) {
+	ipPort := d.IP + ":" + d.Port
+	sessionKey := d.Username + "_" + d.Password + "_" + ipPort
+	old := sessionManager.GetSessionCache(sessionKey)
+	if err := sessionManager.updateSession(d.Username, d.Password, ipPort, brand); err != nil {
+		return nil, err
+	}
+	if old != nil {
+		old.Close()
+	}
+	fresh := sessionManager.GetSessionCache(sessionKey)
+	if d.EnablePassword != "" {
+		if err := fresh.Escalate(d.EnablePassword); err != nil {
+			return nil, err
+		}
+	}
+	return fresh, nil
+}
+
+/**
+ * 外部调用的统一方法，以确认方式推送配置：推送前由设备启动自动恢复定时器（华为commit trial、junos commit confirmed、
+ * H3C configuration commit delay、cisco configure terminal revert timer），推送和检查通过后重新登录设备，登录成功才确认变更，
+ * 无法重新登录时设备在wait到期后自动恢复，结果标记为reverted
+ * @param	lines，配置行，checks，推送后的检查命令，save，确认后是否保存配置，wait，自动恢复的等待时间
+ * @return  推送结果（Outcome为最终状态）和执行错误
+ */
+func (d *Device) PushConfigConfirmed(lines []string, checks []PostCheck, save bool, wait time.Duration) (*TransactionResult, error) {
+	result := &TransactionResult{PushResult: PushResult{IP: d.IP}, Outcome: TxnNotApplied}
+	brand := d.Brand
+	err := d.withSession(func(s *SSHSession) error {
+		pushed, err := s.PushConfigPending(lines, checks, wait, d.getterTimeout())
+		if pushed != nil {
+			pushed.IP = d.IP
+			result = pushed
+		}
+		brand = s.GetSSHBrand()
+		return err
+	})
+	if err == nil {
+		err = d.confirmConfig(brand, save, result)
+	}
+	if err != nil {
+		LogError("确认推送配置错误:%s,IP:%s,状态:%s", err.Error(), d.IP, result.Outcome)
+		return result, err
+	}
+	LogDebug("确认推送配置成功,IP:%s,行数:%d,是否保存:%v", d.IP, len(result.Applied), result.Saved)
+	return result, nil
+}
+
+/**
+ * 使用新会话确认等待中的变更，确认后按需保存配置
+ * @param	brand，推送时识别到的品牌，save，是否保存配置，result，推送结果，Outcome和保存状态直接写入
+ * @return  重新登录、确认或保存的错误
+ */
+func (d *Device) confirmConfig(brand string, save bool, result *TransactionResult) error {
+	driver, ok := GetDriver(brand)
+	if !ok {
+		return fmt.Errorf("品牌%s不支持确认推送", brand)
+	}
+	if d.Port == "" {
+		d.Port = "22"
+	}
+	sessionKey := d.Username + "_" + d.Password + "_" + d.IP + ":" + d.Port
+	sessionManager.LockSession(sessionKey)
+	defer sessionManager.UnlockSession(sessionKey)
+	fresh, err := reconnectDevice(d, brand)
+	if err != nil {
+		result.Outcome = TxnReverted
+		return fmt.Errorf("变更后无法重新登录，设备将自动恢复:%v", err)
+	}
+	if err := checkRevertDeadline(result); err != nil {
+		return err
+	}
+	var output strings.Builder
+	defer func() { result.Output += output.String() }()
+	if errLine, err := fresh.sendConfirmCmds(driver, driver.TransactionCmds().Confirm, d.getterTimeout(), &output); err != nil {
+		//确认命令失败时无法判断定时器是否已取消
+		result.Outcome, result.DeviceError = TxnUnknown, errLine
+		return fmt.Errorf("确认变更失败:%v", err)
+	}
+	result.Outcome = TxnApplied
+	if !save {
+		return nil
+	}
+	return fresh.saveConfig(driver, d.getterTimeout(), &result.PushResult, &output)
+}
+
+/**
+ * 在当前SSH到的设备上推送配置并启动自动恢复定时器，不做确认，需要由新会话执行Driver的Confirm命令确认变更；
+ * 提交前失败时与PushConfigTransaction相同，放弃候选配置或回退到检查点并取消定时器，检查失败时不确认，等待设备自动恢复
+ * @param	lines，配置行，checks，推送后的检查命令，wait，自动恢复的等待时间，timeout，读取单行回显的超时时间（秒）
+ * @return  推送结果和执行错误，成功时Outcome为pending
+ */
+func (s *SSHSession) PushConfigPending(lines []string, checks []PostCheck, wait time.Duration, timeout int) (*TransactionResult, error) {
+	brand := s.GetSSHBrand()
+	driver := s.Driver()
+	if driver == nil {
+		return nil, errors.New("无法识别设备品牌，不能推送配置")
+	}
+	if !driver.TransactionCmds().ConfirmSupported() || len(driver.ConfigEnterCmds()) == 0 {
+		return nil, fmt.Errorf("品牌%s不支持确认推送", brand)
+	}
+	result := &TransactionResult{Outcome: TxnNotApplied}
+	var output strings.Builder
+	defer func() { result.Output = output.String() }()
+	//华为S系列等一阶段生效的设备没有commit trial
+	txn, err := s.transactionCmds(driver, timeout, &output)
+	if err != nil {
+		return result, err
+	}
+	if !txn.ConfirmSupported() {
+		return result, fmt.Errorf("品牌%s一阶段生效的设备不支持确认推送", brand)
+	}
+	if errLine, err := s.sendConfirmCmds(driver, txn.Checkpoint, timeout, &output); err != nil {
+		result.DeviceError = errLine
+		return result, fmt.Errorf("创建检查点失败:%v", err)
+	}
+	if len(txn.Arm) > 0 {
+		armedAt := time.Now()
+		if errLine, err := s.sendConfirmCmds(driver, txn.timerCmds(txn.Arm, wait), timeout, &output); err != nil {
+			result.DeviceError = errLine
+			return result, fmt.Errorf("启动自动恢复定时器失败:%v", err)
+		}
+		result.armTimer(armedAt, txn.timerDuration(wait))
+	}
+	//cisco在进入配置模式时启动定时器，进入失败时定时器未启动，无需取消
+	enter, enteredAt := driver.ConfigEnterCmds(), time.Now()
+	if len(txn.ConfirmedEnter) > 0 {
+		enter = txn.timerCmds(txn.ConfirmedEnter, wait)
+	}
+	for _, cmd := range enter {
+		res, errLine, err := s.sendConfigLine(driver, cmd, timeout)
+		output.WriteString(res)
+		if err != nil {
+			result.FailedLine, result.DeviceError = cmd, errLine
+			s.abortConfig(driver, timeout, &output)
+			if len(txn.Arm) > 0 {
+				s.cancelTimer(driver, timeout, result, &output)
+			}
+			return result, fmt.Errorf("进入配置模式失败:%v", err)
+		}
+	}
+	if len(txn.ConfirmedEnter) > 0 {
+		result.armTimer(enteredAt, txn.timerDuration(wait))
+	}
+	//提交前失败时恢复配置，恢复成功后取消已启动的定时器，恢复失败则交给定时器
+	failed := func(line, errLine string, err error) (*TransactionResult, error) {
+		result.FailedLine, result.DeviceError = line, errLine
+		s.rollbackConfig(driver, txn, true, timeout, result, &output)
+		if len(txn.Arm) > 0 || len(txn.ConfirmedEnter) > 0 {
+			if result.Outcome == TxnRolledBack {
+				s.cancelTimer(driver, timeout, result, &output)
+			} else {
+				result.Outcome = TxnReverted
+			}
+		}
+		return result, fmt.Errorf("配置下发失败:%s,%v", line, err)
+	}
+	for _, line := range configPushLines(driver, lines) {
+		res, errLine, err := s.sendConfigLine(driver, line, timeout)
+		output.WriteString(res)
+		if err != nil {
+			return failed(line, errLine, err)
+		}
+		result.Applied = append(result.Applied, line)
+	}
+	commit := txn.timerCmds(txn.ConfirmedCommit, wait)
+	committedAt := time.Now()
+	if errLine, err := s.sendConfirmCmds(driver, commit, timeout, &output); err != nil {
+		return failed(strings.Join(commit, ";"), errLine, err)
+	}
+	if len(commit) > 0 {
+		result.armTimer(committedAt, txn.timerDuration(wait))
+	}
+	//定时器已生效，之后的失败都交给设备自动恢复；退出配置模式时不能再次提交（如junos的commit and-quit），使用ConfigAbortCmds
+	result.Outcome = TxnPending
+	if errLine, err := s.sendConfirmCmds(driver, driver.ConfigAbortCmds(), timeout, &output); err != nil {
+		result.Outcome, result.DeviceError = TxnReverted, errLine
+		return result, fmt.Errorf("退出配置模式失败，设备将自动恢复:%v", err)
+	}
+	for _, check := range checks {
+		if err := s.runPostCheck(driver, check, timeout, &output); err != nil {
+			result.Outcome, result.FailedCheck = TxnReverted, check.Cmd
+			return result, fmt.Errorf("推送后检查失败，设备将自动恢复:%s,%v", check.Cmd, err)
+		}
+	}
+	if err := checkRevertDeadline(result); err != nil {
+		return result, err
+	}
+	return result, nil
+}
+
+// 记录定时器启动的时间，发送命令前记录，计算出的恢复时间不会晚于设备上的实际时间
+func (r *TransactionResult) armTimer(armedAt time.Time, duration time.Duration) {
+	r.ArmedAt, r.RevertAt = armedAt, armedAt.Add(duration)
+}
+
+// 已超过（或即将到达）自动恢复时间时不能再确认，设备已经或即将自动恢复，Outcome标记为reverted
+func checkRevertDeadline(result *TransactionResult) error {
+	if result.RevertAt.IsZero() || time.Until(result.RevertAt) > confirmMargin {
+		return nil
+	}
+	result.Outcome = TxnReverted
+	return fmt.Errorf("已超过自动恢复时间%s，不再确认，设备将自动恢复", result.RevertAt.Format("2006-01-02 15:04:05"))
+}
+
+// 取消已启动的自动恢复定时器，失败时记录在结果中
+func (s *SSHSession) cancelTimer(driver Driver, timeout int, result *TransactionResult, output *strings.Builder) {
+	if _, err := s.sendConfirmCmds(driver, driver.TransactionCmds().Confirm, timeout, output); err != nil {
+		result.Outcome, result.RollbackError = TxnReverted, "取消自动恢复定时器失败:"+err.Error()
+	}
+}
@@ -0,0 +1,106 @@
+package arkssh
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestTimerCmds(t *testing.T) {
+	huawei, _ := GetDriver(HUAWEI)
+	if got := huawei.TransactionCmds().timerCmds(huawei.TransactionCmds().ConfirmedCommit, 90*time.Second); got[0] != "commit trial 90" {
+		t.Fatalf("huawei got %v", got)
+	}
+	//华为commit trial最少60秒
+	if got := huawei.TransactionCmds().timerCmds(huawei.TransactionCmds().ConfirmedCommit, 30*time.Second); got[0] != "commit trial 60" {
+		t.Fatalf("huawei minimum got %v", got)
+	}
+	//不足一分钟按一分钟计算
+	cisco, _ := GetDriver(CISCO)
+	if got := cisco.TransactionCmds().timerCmds(cisco.TransactionCmds().ConfirmedEnter, 90*time.Second); got[0] != "configure terminal revert timer 2" {
+		t.Fatalf("cisco got %v", got)
+	}
+	if got := cisco.TransactionCmds().timerCmds(cisco.TransactionCmds().ConfirmedEnter, 0); got[0] != "configure terminal revert timer 1" {
+		t.Fatalf("cisco zero wait got %v", got)
+	}
+}
+
+func TestPushConfigPending(t *testing.T) {
+	s, received := newScriptedSession(HUAWEI, huaweiTxnReply)
+	checks := []PostCheck{{Cmd: "display bgp peer verbose", Expect: "Established"}}
+	result, err := s.PushConfigPending([]string{"acl number 3001", "rule 5 permit ip"}, checks, 5*time.Minute, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	//带自动恢复时间提交，退出配置模式时不再提交
+	want := []string{"system-view", "return", "system-view", "acl number 3001", "rule 5 permit ip", "commit trial 300", "return", "display bgp peer verbose"}
+	if strings.Join(*received, "|") != strings.Join(want, "|") {
+		t.Fatalf("sent %q, want %q", *received, want)
+	}
+	if result.Outcome != TxnPending || len(result.Applied) != 2 || result.RevertAt.Sub(result.ArmedAt) != 5*time.Minute {
+		t.Fatalf("result got %+v", result)
+	}
+}
+
+func TestPushConfigPendingCancelTimer(t *testing.T) {
+	s, received := newScriptedSession(CISCO, func(cmd string) string {
+		switch {
+		case strings.HasPrefix(cmd, "copy running-config"):
+			return "Destination filename [arkssh_checkpoint.cfg]? "
+		case strings.HasPrefix(cmd, "configure terminal"):
+			return "\r\nSW-R1(config)#"
+		case strings.HasPrefix(cmd, "bad"):
+			return "                ^\r\n% Invalid input detected at '^' marker.\r\nSW-R1(config)#"
+		default:
+			return "\r\nSW-R1#"
+		}
+	})
+	result, err := s.PushConfigPending([]string{"ip access-list extended MGMT", "bad rule"}, nil, 5*time.Minute, 1)
+	if err == nil {
+		t.Fatal("push should fail")
+	}
+	//进入配置模式时启动revert定时器，回退到检查点后确认，取消定时器
+	want := []string{"copy running-config flash:arkssh_checkpoint.cfg", "", "configure terminal revert timer 5",
+		"ip access-list extended MGMT", "bad rule", "end", "configure replace flash:arkssh_checkpoint.cfg force", "configure confirm"}
+	if strings.Join(*received, "|") != strings.Join(want, "|") {
+		t.Fatalf("sent %q, want %q", *received, want)
+	}
+	if result.Outcome != TxnRolledBack || result.FailedLine != "bad rule" {
+		t.Fatalf("result got %+v", result)
+	}
+}
+
+func TestConfirmConfig(t *testing.T) {
+	defer func(origin func(*Device, string) (*SSHSession, error)) { reconnectDevice = origin }(reconnectDevice)
+	d := &Device{IP: "10.1.1.1", Username: "admin", Password: "pass"}
+	//无法重新登录时不确认，等待设备自动恢复
+	reconnectDevice = func(*Device, string) (*SSHSession, error) {
+		return nil, errors.New("dial tcp 10.1.1.1:22: i/o timeout")
+	}
+	result := &TransactionResult{Outcome: TxnPending}
+	if err := d.confirmConfig(HUAWEI, true, result); err == nil || result.Outcome != TxnReverted {
+		t.Fatalf("reconnect failure got %+v, err:%v", result, err)
+	}
+	//超过自动恢复时间后不再确认
+	fresh, received := newScriptedSession(HUAWEI, huaweiReply)
+	reconnectDevice = func(*Device, string) (*SSHSession, error) { return fresh, nil }
+	armedAt := time.Now().Add(-2 * time.Minute)
+	result = &TransactionResult{Outcome: TxnPending}
+	result.armTimer(armedAt, time.Minute)
+	if err := d.confirmConfig(HUAWEI, true, result); err == nil || result.Outcome != TxnReverted || len(*received) != 0 {
+		t.Fatalf("expired timer got %+v, sent %q, err:%v", result, *received, err)
+	}
+	result = &TransactionResult{Outcome: TxnPending}
+	result.armTimer(time.Now(), time.Minute)
+	if err := d.confirmConfig(HUAWEI, true, result); err != nil {
+		t.Fatal(err)
+	}
+	want := []string{"system-view", "commit", "return", "save", "Y"}
+	if strings.Join(*received, "|") != strings.Join(want, "|") {
+		t.Fatalf("sent %q, want %q", *received, want)
+	}
+	if result.Outcome != TxnApplied || !result.Saved {
+		t.Fatalf("result got %+v", result)
+	}
+}
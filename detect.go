@@ -0,0 +1,171 @@
+package arkssh
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+)
+
+// 品牌识别结果的可信度，JSON中序列化为none/low/medium/high，bson中保存为数值（0-3）
+type Confidence int
+
+const (
+	ConfidenceNone   Confidence = iota // 无法识别
+	ConfidenceLow                      // 仅根据提示符形状推断
+	ConfidenceMedium                   // 根据登录banner或登录回显识别
+	ConfidenceHigh                     // 根据SSH服务端版本或探测命令的回显识别
+)
+
+func (c Confidence) String() string {
+	switch c {
+	case ConfidenceLow:
+		return "low"
+	case ConfidenceMedium:
+		return "medium"
+	case ConfidenceHigh:
+		return "high"
+	default:
+		return "none"
+	}
+}
+
+func (c Confidence) MarshalJSON() ([]byte, error) {
+	return json.Marshal(c.String())
+}
+
+// 兼容数值和字符串两种格式
+func (c *Confidence) UnmarshalJSON(data []byte) error {
+	var name string
+	if err := json.Unmarshal(data, &name); err != nil {
+		var n int
+		if err := json.Unmarshal(data, &n); err != nil {
+			return err
+		}
+		*c = Confidence(n)
+		return nil
+	}
+	for _, v := range []Confidence{ConfidenceNone, ConfidenceLow, ConfidenceMedium, ConfidenceHigh} {
+		if v.String() == name {
+			*c = v
+			return nil
+		}
+	}
+	return fmt.Errorf("未知的可信度:%s", name)
+}
+
+// 品牌识别的依据
+const (
+	DetectSourceServerVersion = "server_version"
+	DetectSourceBanner        = "banner"
+	DetectSourcePrompt        = "prompt"
+	DetectSourceProbe         = "probe"
+	DetectSourceDevice        = "device" // 品牌由调用方在Device中指定
+)
+
+/**
+ * 品牌识别的结果
+ * @attr Brand:品牌名称，为空表示无法识别，Confidence:可信度，Source:识别依据
+ */
+type DetectResult struct {
+	Brand      string     `bson:"brand,omitempty" json:"brand,omitempty"`
+	Confidence Confidence `bson:"confidence" json:"confidence"`
+	Source     string     `bson:"source,omitempty" json:"source,omitempty"`
+}
+
+/**
+ * 识别当前SSH到的设备的品牌，优先使用不需要发送命令的信息，有歧义时才逐条发送探测命令
+ * 1、SSH服务端版本（如SSH-2.0-HUAWEI-1.5、SSH-2.0-Comware-7.1.064）
+ * 2、登录banner和登录后的回显
+ * 3、提示符的形状（<x>、x#），仅剩一个候选品牌时直接采用，否则只向候选品牌发送探测命令
+ * @return 识别结果
+ */
+func (s *SSHSession) DetectBrand() DetectResult {
+	if s.detectResult.Brand != "" {
+		return s.detectResult
+	}
+	if s.brand != "" {
+		s.detectResult = DetectResult{Brand: s.brand, Confidence: ConfidenceHigh, Source: DetectSourceDevice}
+		return s.detectResult
+	}
+	drivers := Drivers()
+	// 1、SSH服务端版本
+	if brand, ok := detectByBanner(drivers, s.serverVersion); ok {
+		return s.setDetectResult(brand, ConfidenceHigh, DetectSourceServerVersion)
+	}
+	// 2、登录banner和登录回显
+	if brand, ok := detectByBanner(drivers, s.banner+"\n"+s.loginOutput); ok {
+		return s.setDetectResult(brand, ConfidenceMedium, DetectSourceBanner)
+	}
+	// 3、提示符形状
+	candidates := detectByPrompt(drivers, s.loginOutput)
+	if len(candidates) == 1 {
+		return s.setDetectResult(candidates[0].Name(), ConfidenceLow, DetectSourcePrompt)
+	}
+	if len(candidates) == 0 {
+		candidates = drivers
+	}
+	// 4、逐条发送候选品牌的探测命令，识别到即停止
+	sent := make(map[string]bool)
+	for _, driver := range candidates {
+		for _, cmd := range driver.ProbeCmds() {
+			if sent[cmd] {
+				continue
+			}
+			sent[cmd] = true
+			//探测命令后多发一组空格，避免版本信息过多需要分页，导致下一条命令第一个字符失效的问题
+			s.WriteChannel(cmd, "     ")
+			result, _ := s.ReadChannelTiming(5)
+			if found, ok := DetectDriver(result); ok {
+				//只有查看版本的命令的回显才能复用，其他探测命令（如zte的show privilege）不能当作版本信息解析
+				if cmd == found.VersionCmd() {
+					s.versionOutput = result
+				}
+				return s.setDetectResult(found.Name(), ConfidenceHigh, DetectSourceProbe)
+			}
+		}
+	}
+	return s.detectResult
+}
+
+func (s *SSHSession) setDetectResult(brand string, confidence Confidence, source string) DetectResult {
+	LogDebug("The device brand is <%s>, confidence:%s, source:%s.", brand, confidence, source)
+	s.detectResult = DetectResult{Brand: brand, Confidence: confidence, Source: source}
+	s.brand = brand
+	return s.detectResult
+}
+
+// 根据SSH服务端版本或banner识别品牌，只有唯一的品牌匹配时才认为识别成功
+func detectByBanner(drivers []Driver, text string) (string, bool) {
+	text = strings.ToLower(strings.TrimSpace(text))
+	if text == "" {
+		return "", false
+	}
+	matched := ""
+	for _, driver := range drivers {
+		if !driver.DetectBanner(text) {
+			continue
+		}
+		if matched != "" && matched != driver.Name() {
+			return "", false
+		}
+		matched = driver.Name()
+	}
+	return matched, matched != ""
+}
+
+// 根据登录回显最后一行的提示符形状，筛选出可能的品牌
+func detectByPrompt(drivers []Driver, loginOutput string) []Driver {
+	lines := strings.Split(strings.TrimRight(strings.ReplaceAll(loginOutput, "\r", ""), " \n"), "\n")
+	prompt := "\n" + lines[len(lines)-1]
+	candidates := make([]Driver, 0)
+	for _, driver := range drivers {
+		for _, pattern := range driver.PromptPatterns() {
+			re := cachedRegexp(pattern)
+			if re != nil && re.MatchString(prompt) {
+				candidates = append(candidates, driver)
+				break
+			}
+		}
+	}
+	return candidates
+}
@@ -0,0 +1,69 @@
+package arkssh
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+)
+
+func TestDetectBrandWithoutProbe(t *testing.T) {
+	cases := []struct {
+		session    SSHSession
+		brand      string
+		confidence Confidence
+	}{
+		{SSHSession{serverVersion: "SSH-2.0-HUAWEI-1.5", loginOutput: "\r\n<SW-01>"}, HUAWEI, ConfidenceHigh},
+		{SSHSession{serverVersion: "SSH-2.0-Comware-7.1.064", loginOutput: "\r\n<SW-02>"}, H3C, ConfidenceHigh},
+		{SSHSession{serverVersion: "SSH-2.0-Cisco-1.25", loginOutput: "\r\nR1#"}, CISCO, ConfidenceHigh},
+		{SSHSession{serverVersion: "SSH-2.0-OpenSSH_7.4", loginOutput: "Info: The max number of VTY users is 10, and the number\r\n<SW-03>"}, HUAWEI, ConfidenceMedium},
+		{SSHSession{serverVersion: "SSH-2.0-OpenSSH_7.4", loginOutput: "Last login: Sat Oct 18 10:00:00 2026\r\n[root@server-01 ~]# "}, LINUX, ConfidenceLow},
+	}
+	for _, c := range cases {
+		got := c.session.DetectBrand()
+		if got.Brand != c.brand || got.Confidence != c.confidence {
+			t.Errorf("DetectBrand(%q)=%+v, want %s/%s", c.session.serverVersion, got, c.brand, c.confidence)
+		}
+	}
+}
+
+func TestDetectBrandProbeVersionOutput(t *testing.T) {
+	reply := func(version string) func(string) string {
+		return func(cmd string) string {
+			switch cmd {
+			case "show version":
+				return version + "\r\nSW-R1#"
+			case "show privilege":
+				return "Current privilege level is 15\r\nSW-R1#"
+			default:
+				return "\r\nSW-R1#"
+			}
+		}
+	}
+	//show privilege识别出的品牌不缓存其回显
+	s, _ := newScriptedSession("", reply("Software, Version 1.0"))
+	s.loginOutput = "\r\nSW-R1#"
+	if got := s.DetectBrand(); got.Brand != ZTE || s.versionOutput != "" {
+		t.Fatalf("privilege probe got %+v, version output %q", got, s.versionOutput)
+	}
+	//查看版本的命令识别出品牌时缓存回显，供GetFacts复用
+	s, _ = newScriptedSession("", reply("ZXR10 ROS Version V4.08.23, ZTE Corporation"))
+	s.loginOutput = "\r\nSW-R1#"
+	if got := s.DetectBrand(); got.Brand != ZTE || !strings.Contains(s.versionOutput, "ZXR10 ROS") {
+		t.Fatalf("version probe got %+v, version output %q", got, s.versionOutput)
+	}
+}
+
+func TestConfidenceJSON(t *testing.T) {
+	data, err := json.Marshal(DetectResult{Brand: HUAWEI, Confidence: ConfidenceMedium})
+	if err != nil || !strings.Contains(string(data), `"confidence":"medium"`) {
+		t.Fatalf("marshal got %s, err:%v", data, err)
+	}
+	var got DetectResult
+	if err := json.Unmarshal(data, &got); err != nil || got.Confidence != ConfidenceMedium {
+		t.Fatalf("unmarshal got %+v, err:%v", got, err)
+	}
+	//兼容旧的数值格式
+	if err := json.Unmarshal([]byte(`{"confidence":3}`), &got); err != nil || got.Confidence != ConfidenceHigh {
+		t.Fatalf("numeric unmarshal got %+v, err:%v", got, err)
+	}
+}
@@ -0,0 +1,851 @@
+package arkssh
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+)
+
+/**
+ * 设备厂商行为的抽象，新增厂商时实现该接口并通过RegisterDriver注册，无需修改本包
+ * 自定义Driver建议内嵌BaseDriver，只覆盖需要定制的方法，这样接口新增方法时不会影响已有实现
+ */
+type Driver interface {
+	// Name 品牌名称，同时作为common/textfsm下模板目录的名称
+	Name() string
+	// Detect 根据设备回显（已转为小写）判断是否为该品牌
+	Detect(output string) bool
+	// NoPageCmds 禁止分页的命令
+	NoPageCmds() []string
+	// PromptPatterns 提示符的正则，会与PROMPT合并使用
+	PromptPatterns() []string
+	// PrivilegeCmd 提权命令（如enable、super），为空表示不需要提权
+	PrivilegeCmd() string
+	// ConfigEnterCmds 进入配置模式的命令
+	ConfigEnterCmds() []string
+	// ConfigExitCmds 退出配置模式的命令
+	ConfigExitCmds() []string
+	// ConfigAbortCmds 推送失败时退出配置模式的命令，需要提交的品牌（如junos）不能提交未完成的配置
+	ConfigAbortCmds() []string
+	// ConfigAbortFallbackCmd ConfigAbortCmds中的命令报错（如设备版本不支持）时改为执行的命令，为空表示没有替代命令
+	ConfigAbortFallbackCmd(cmd string) string
+	// TwoStagePattern 进入配置模式后提示符匹配该正则时为两阶段提交（如华为CE的[~HOSTNAME]），配置需要按TransactionCmds的Commit提交后才生效
+	TwoStagePattern() string
+	// SaveCmds 保存配置的命令（包含确认输入）
+	SaveCmds() []string
+	// ErrorPatterns 命令执行失败时回显中出现的正则
+	ErrorPatterns() []string
+	// LogoutCmds 退出登录的命令
+	LogoutCmds() []string
+	// VersionCmd 查看版本信息的命令
+	VersionCmd() string
+	// InventoryCmds 查看序列号等资产信息的命令
+	InventoryCmds() []string
+	// RunningConfigCmd 查看当前运行配置的命令，为空表示不支持配置备份
+	RunningConfigCmd() string
+	// StartupConfigCmd 查看已保存（下次启动）配置的命令，为空表示不支持
+	StartupConfigCmd() string
+	// DetectBanner 根据SSH服务端版本、登录banner或登录回显（已转为小写）判断是否为该品牌，无需发送命令
+	DetectBanner(text string) bool
+	// ProbeCmds 无法通过banner识别时发送的探测命令，回显交给Detect判断
+	ProbeCmds() []string
+	// FormatCmd 发送前对命令做厂商相关的处理（如junos的show命令追加| no-more）
+	FormatCmd(cmd string) string
+	// ContextCmds 切换到指定虚拟系统（VDOM/vsys）的命令，为空表示不支持
+	ContextCmds(name string) []string
+	// ContextExitCmds 退出虚拟系统的命令
+	ContextExitCmds() []string
+	// ContextCheckCmds 切换虚拟系统前确认其存在的命令，为空表示不检查（fortinet的edit会直接创建不存在的VDOM）
+	ContextCheckCmds() []string
+	// ContextExistsPattern ContextCheckCmds的回显中虚拟系统存在时匹配的正则
+	ContextExistsPattern(name string) string
+	// GetterCmds 结构化采集（如GetInterfaces）执行的命令，回显按模板索引解析，为空表示不支持
+	GetterCmds(name string) []string
+	// VRFCmd 在查看命令中指定VPN实例/VRF（如display ip routing-table vpn-instance x、show ip route vrf x），vrf为空时返回原命令
+	VRFCmd(cmd, vrf string) string
+	// TransactionCmds 事务推送使用的检查点、提交和回退命令，均为空表示不支持事务推送
+	TransactionCmds() TransactionCmds
+}
+
+/**
+ * 事务推送使用的命令，需要确认的命令（如[Y/N]）按Answers应答，无需包含确认输入
+ * @attr Checkpoint:推送前在用户视图创建检查点的命令，Commit:在配置模式中提交候选配置的命令，为空表示配置即时生效，
+ *       Discard:提交前在配置模式中放弃候选配置的命令，Rollback:配置生效后在用户视图恢复到推送前状态的命令，
+ *       Arm:推送前在用户视图启动自动恢复定时器的命令（如H3C的configuration commit delay），
+ *       ConfirmedEnter:确认推送时代替ConfigEnterCmds进入配置模式并启动自动恢复定时器的命令（如cisco的configure terminal revert timer），
+ *       ConfirmedCommit:在配置模式中带自动恢复时间提交的命令（如commit trial、commit confirmed），
+ *       Confirm:在新会话中确认变更、取消自动恢复的命令，ConfirmUnit:Arm、ConfirmedEnter和ConfirmedCommit中%d的时间单位，为0时按分钟，ConfirmMin:自动恢复时间的下限（如华为commit trial最少60秒），
+ *       Answers:命令的确认提示及应答，出现未定义应答的确认提示时视为失败，OneStage:定义了TwoStagePattern的品牌在一阶段生效的设备上改用的命令（如华为S系列没有commit），Answers使用外层的定义
+ */
+type TransactionCmds struct {
+	Checkpoint      []string
+	Commit          []string
+	Discard         []string
+	Rollback        []string
+	Arm             []string
+	ConfirmedEnter  []string
+	ConfirmedCommit []string
+	Confirm         []string
+	ConfirmUnit     time.Duration
+	ConfirmMin      time.Duration
+	Answers         []PromptAnswer
+	OneStage        *TransactionCmds
+}
+
+/**
+ * 命令的确认提示及应答，不同命令的同类提示可能需要不同的应答（如H3C的configuration replace询问是否保存当前配置时应答N）
+ * @attr Cmd:命令的前缀（%d替换前的部分即可），Prompt:确认提示的正则，Answer:应答内容，为空时只发送回车
+ */
+type PromptAnswer struct {
+	Cmd    string
+	Prompt string
+	Answer string
+}
+
+// 查找命令的确认提示对应的应答
+func (t TransactionCmds) answer(cmd, prompt string) (string, bool) {
+	for _, answer := range t.Answers {
+		if !strings.HasPrefix(cmd, answer.Cmd) {
+			continue
+		}
+		if re := cachedRegexp(answer.Prompt); re != nil && re.MatchString(prompt) {
+			return answer.Answer, true
+		}
+	}
+	return "", false
+}
+
+// Supported 是否定义了恢复配置的方式
+func (t TransactionCmds) Supported() bool {
+	return len(t.Discard) > 0 || len(t.Rollback) > 0
+}
+
+// ConfirmSupported 是否支持由设备定时自动恢复、确认后才生效的推送
+func (t TransactionCmds) ConfirmSupported() bool {
+	return len(t.Confirm) > 0 && (len(t.Arm) > 0 || len(t.ConfirmedEnter) > 0 || len(t.ConfirmedCommit) > 0)
+}
+
+// 设备实际使用的自动恢复时间：不足ConfirmMin时按ConfirmMin，不足一个单位的部分按一个单位计算
+func (t TransactionCmds) timerDuration(wait time.Duration) time.Duration {
+	unit := t.ConfirmUnit
+	if unit <= 0 {
+		unit = time.Minute
+	}
+	if wait < t.ConfirmMin {
+		wait = t.ConfirmMin
+	}
+	n := (wait + unit - 1) / unit
+	if n < 1 {
+		n = 1
+	}
+	return n * unit
+}
+
+// 将命令中的%d替换为自动恢复的时间，时间按timerDuration计算
+func (t TransactionCmds) timerCmds(cmds []string, wait time.Duration) []string {
+	unit := t.ConfirmUnit
+	if unit <= 0 {
+		unit = time.Minute
+	}
+	n := int(t.timerDuration(wait) / unit)
+	formatted := make([]string, 0, len(cmds))
+	for _, cmd := range cmds {
+		if strings.Contains(cmd, "%d") {
+			cmd = fmt.Sprintf(cmd, n)
+		}
+		formatted = append(formatted, cmd)
+	}
+	return formatted
+}
+
+/**
+ * Driver的通用实现，各方法直接返回对应字段，内置的厂商均使用该结构体定义
+ * @attr Brand:品牌名称，DetectKeywords:回显中出现任意一个关键字即认为是该品牌，其余字段与Driver接口的方法一一对应
+ */
+type BaseDriver struct {
+	Brand          string
+	DetectKeywords []string
+	NoPage         []string
+	NoPageVars     []*string //指向HuaweiNoPage等包级变量，发送时才读取，运行时修改这些变量仍然生效；NoPage不为空时忽略
+	Prompts        []string
+	Privilege      string
+	ConfigEnter    []string
+	ConfigExit     []string
+	ConfigAbort    []string          //推送失败时退出配置模式的命令，为空时与ConfigExit相同
+	AbortFallback  map[string]string //ConfigAbort中的命令报错时改为执行的命令，key为原命令
+	TwoStagePrompt string            //两阶段提交时配置模式提示符的正则，为空表示配置即时生效
+	Save           []string
+	Errors         []string
+	Logout         []string
+	Version        string
+	Inventory      []string
+	RunningConfig  string
+	StartupConfig  string
+	BannerKeywords []string
+	Probes         []string
+	ShowSuffix     string   //追加在show命令之后的内容，已包含管道符的命令不追加
+	ContextEnter   []string //切换虚拟系统的命令，其中的%s会被替换为虚拟系统名称
+	ContextExit    []string
+	ContextCheck   []string            //切换前确认虚拟系统存在的命令
+	ContextExists  string              //ContextCheck的回显中虚拟系统存在时匹配的正则，其中的%s会被替换为转义后的虚拟系统名称
+	Getters        map[string][]string //结构化采集的命令，key为采集名称（如GetterInterfaces）
+	VRFKeyword     string              //在命令后指定VPN实例/VRF的关键字（如vpn-instance、vrf）
+	Transaction    TransactionCmds     //事务推送使用的命令
+}
+
+func (b BaseDriver) Name() string { return b.Brand }
+
+func (b BaseDriver) Detect(output string) bool {
+	for _, keyword := range b.DetectKeywords {
+		if strings.Contains(output, keyword) {
+			return true
+		}
+	}
+	return false
+}
+
+func (b BaseDriver) PromptPatterns() []string  { return b.Prompts }
+func (b BaseDriver) PrivilegeCmd() string      { return b.Privilege }
+func (b BaseDriver) ConfigEnterCmds() []string { return b.ConfigEnter }
+func (b BaseDriver) ConfigExitCmds() []string  { return b.ConfigExit }
+func (b BaseDriver) SaveCmds() []string        { return b.Save }
+func (b BaseDriver) ErrorPatterns() []string   { return b.Errors }
+func (b BaseDriver) LogoutCmds() []string      { return b.Logout }
+func (b BaseDriver) VersionCmd() string        { return b.Version }
+func (b BaseDriver) InventoryCmds() []string   { return b.Inventory }
+func (b BaseDriver) RunningConfigCmd() string  { return b.RunningConfig }
+func (b BaseDriver) StartupConfigCmd() string  { return b.StartupConfig }
+func (b BaseDriver) TwoStagePattern() string   { return b.TwoStagePrompt }
+
+func (b BaseDriver) ConfigAbortFallbackCmd(cmd string) string { return b.AbortFallback[cmd] }
+
+// 未定义ConfigAbort的品牌配置即时生效，直接按ConfigExit退出
+func (b BaseDriver) ConfigAbortCmds() []string {
+	if len(b.ConfigAbort) == 0 {
+		return b.ConfigExit
+	}
+	return b.ConfigAbort
+}
+
+// 内置Driver通过NoPageVars引用包级变量，保持运行时修改HuaweiNoPage等变量的用法
+func (b BaseDriver) NoPageCmds() []string {
+	if len(b.NoPage) > 0 || len(b.NoPageVars) == 0 {
+		return b.NoPage
+	}
+	cmds := make([]string, 0, len(b.NoPageVars))
+	for _, v := range b.NoPageVars {
+		cmds = append(cmds, *v)
+	}
+	return cmds
+}
+
+func (b BaseDriver) DetectBanner(text string) bool {
+	for _, keyword := range b.BannerKeywords {
+		if strings.Contains(text, keyword) {
+			return true
+		}
+	}
+	return false
+}
+
+// 未指定探测命令时使用查看版本的命令
+func (b BaseDriver) ProbeCmds() []string {
+	if len(b.Probes) == 0 && b.Version != "" {
+		return []string{b.Version}
+	}
+	return b.Probes
+}
+
+func (b BaseDriver) FormatCmd(cmd string) string {
+	if b.ShowSuffix == "" || !strings.HasPrefix(cmd, "show ") || strings.Contains(cmd, "|") {
+		return cmd
+	}
+	return cmd + " " + b.ShowSuffix
+}
+
+func (b BaseDriver) ContextCmds(name string) []string {
+	cmds := make([]string, 0, len(b.ContextEnter))
+	for _, cmd := range b.ContextEnter {
+		if strings.Contains(cmd, "%s") {
+			cmd = fmt.Sprintf(cmd, name)
+		}
+		cmds = append(cmds, cmd)
+	}
+	return cmds
+}
+
+func (b BaseDriver) ContextExitCmds() []string { return b.ContextExit }
+
+func (b BaseDriver) ContextCheckCmds() []string { return b.ContextCheck }
+
+func (b BaseDriver) ContextExistsPattern(name string) string {
+	if b.ContextExists == "" {
+		return ""
+	}
+	return strings.ReplaceAll(b.ContextExists, "%s", regexp.QuoteMeta(name))
+}
+
+func (b BaseDriver) GetterCmds(name string) []string { return b.Getters[name] }
+
+func (b BaseDriver) TransactionCmds() TransactionCmds { return b.Transaction }
+
+// 未定义VRFKeyword的品牌忽略vrf
+func (b BaseDriver) VRFCmd(cmd, vrf string) string {
+	if vrf == "" || b.VRFKeyword == "" {
+		return cmd
+	}
+	return cmd + " " + b.VRFKeyword + " " + vrf
+}
+
+var (
+	huaweiErrors = []string{`Error:`, `Unrecognized command`, `Incomplete command`, `Wrong parameter`, `Too many parameters`}
+	ciscoErrors  = []string{`% Invalid input`, `% Incomplete command`, `% Ambiguous command`, `% Unknown command`}
+	//华为的确认提示有[Y/N]:和(y/n)[n]:两种格式
+	huaweiYesNo = `(?i)\[y/n\]|\(y/n\)`
+)
+
+// 内置的厂商，顺序即品牌识别的优先级，linux和cisco的关键字最宽泛，需要放在后面
+var (
+	driverRegistry = []Driver{
+		BaseDriver{
+			Brand:          HUAWEI,
+			DetectKeywords: []string{HUAWEI, HUARONG, FutureMatrix},
+			NoPageVars:     []*string{&HuaweiNoPage},
+			Prompts:        []string{`\n<[^!]{1,100}>\s*$`, `\n\[[^\]]{1,100}\]\s*$`},
+			Privilege:      "super",
+			ConfigEnter:    []string{"system-view"},
+			ConfigExit:     []string{"return"},
+			TwoStagePrompt: `\n\[[~*][^\]]{1,100}\]\s*$`,
+			Save:           []string{"save", "Y"},
+			Errors:         huaweiErrors,
+			Logout:         []string{"quit"},
+			Version:        "display version",
+			RunningConfig:  "display current-configuration",
+			StartupConfig:  "display saved-configuration",
+			Inventory:      []string{"display esn"},
+			BannerKeywords: []string{HUAWEI, FutureMatrix, "the max number of vty users"},
+			Getters: map[string][]string{
+				GetterInterfaces:  {"display current-configuration interface", "display interface"},
+				GetterMACTable:    {"display mac-address"},
+				GetterEnvironment: {"display power", "display fan", "display temperature all"},
+				GetterHAStatus:    {"display stack", "display dfs-group %s m-lag", "display m-lag peer-link", "display vrrp brief"},
+				GetterMLAGGroups:  {"display current-configuration configuration dfs-group"},
+				GetterNeighbors:   {"display lldp neighbor"},
+				GetterARPTable:    {"display arp all"},
+				GetterRoutes:      {"display ip routing-table"},
+			},
+			VRFKeyword: "vpn-instance",
+			Transaction: TransactionCmds{
+				Commit:          []string{"commit"},
+				Discard:         []string{"clear configuration candidate"},
+				Rollback:        []string{"rollback configuration last 1"},
+				ConfirmedCommit: []string{"commit trial %d"},
+				Confirm:         []string{"system-view", "commit", "return"},
+				ConfirmUnit:     time.Second,
+				ConfirmMin:      time.Minute,
+				Answers: []PromptAnswer{
+					{Cmd: "clear configuration candidate", Prompt: huaweiYesNo, Answer: "Y"},
+					{Cmd: "rollback configuration", Prompt: huaweiYesNo, Answer: "Y"},
+					{Cmd: "save arkssh_checkpoint.cfg", Prompt: huaweiYesNo, Answer: "Y"},
+				},
+				//S系列等一阶段生效的设备没有commit，按保存的检查点文件回退
+				OneStage: &TransactionCmds{
+					Checkpoint: []string{"save arkssh_checkpoint.cfg"},
+					Rollback:   []string{"rollback configuration to file arkssh_checkpoint.cfg"},
+				},
+			},
+		},
+		BaseDriver{
+			Brand:          H3C,
+			DetectKeywords: []string{H3C},
+			NoPageVars:     []*string{&H3cNoPage},
+			Prompts:        []string{`\n<[^!]{1,100}>\s*$`, `\n\[[^\]]{1,100}\]\s*$`},
+			Privilege:      "super",
+			ConfigEnter:    []string{"system-view"},
+			ConfigExit:     []string{"return"},
+			Save:           []string{"save force"},
+			Errors:         []string{`% Unrecognized command`, `% Incomplete command`, `% Wrong parameter`, `% Too many parameters`, `% Ambiguous command`},
+			Logout:         []string{"quit"},
+			Version:        "display version",
+			RunningConfig:  "display current-configuration",
+			StartupConfig:  "display saved-configuration",
+			Inventory:      []string{"display device manuinfo"},
+			BannerKeywords: []string{H3C, "comware"},
+			Getters: map[string][]string{
+				GetterInterfaces:  {"display current-configuration interface", "display interface"},
+				GetterMACTable:    {"display mac-address"},
+				GetterEnvironment: {"display power", "display fan", "display environment"},
+				GetterHAStatus:    {"display irf", "display m-lag summary", "display m-lag role", "display vrrp"},
+				GetterNeighbors:   {"display lldp neighbor-information verbose"},
+				GetterARPTable:    {"display arp"},
+				GetterRoutes:      {"display ip routing-table"},
+			},
+			VRFKeyword: "vpn-instance",
+			Transaction: TransactionCmds{
+				Checkpoint: []string{"save flash:/arkssh_checkpoint.cfg"},
+				Rollback:   []string{"system-view", "configuration replace file flash:/arkssh_checkpoint.cfg", "return"},
+				Arm:        []string{"system-view", "configuration commit delay %d", "return"},
+				Confirm:    []string{"system-view", "configuration commit", "return"},
+				Answers: []PromptAnswer{
+					{Cmd: "save flash:/arkssh_checkpoint.cfg", Prompt: `(?i)\[y/n\]`, Answer: "Y"},
+					//替换配置前询问是否保存当前配置，保存会覆盖启动配置
+					{Cmd: "configuration replace file", Prompt: `(?i)save current configuration\?\s*\[y/n\]`, Answer: "N"},
+				},
+			},
+		},
+		BaseDriver{
+			Brand:          JUNIPER,
+			DetectKeywords: []string{"junos", "juniper"},
+			NoPageVars:     []*string{&JuniperNoPage},
+			Prompts:        []string{`\n[\w.-]+@[\w.-]+[>#]\s*$`},
+			ConfigEnter:    []string{"configure"},
+			ConfigExit:     []string{"commit and-quit"},
+			ConfigAbort:    []string{"rollback 0", "exit configuration-mode"},
+			Errors:         []string{`^\s*error:`, `^\s*syntax error`, `unknown command`, `missing argument`},
+			Logout:         []string{"exit"},
+			Version:        "show version",
+			RunningConfig:  "show configuration",
+			Inventory:      []string{"show chassis hardware"},
+			BannerKeywords: []string{"junos"},
+			ShowSuffix:     "| no-more",
+			Getters: map[string][]string{
+				GetterInterfaces: {"show interfaces terse"},
+			},
+			Transaction: TransactionCmds{
+				Commit:          []string{"commit"},
+				Discard:         []string{"rollback 0"},
+				Rollback:        []string{"configure", "rollback 1", "commit", "exit"},
+				ConfirmedCommit: []string{"commit confirmed %d"},
+				Confirm:         []string{"configure", "commit", "exit"},
+			},
+		},
+		BaseDriver{
+			Brand:          ARISTA,
+			DetectKeywords: []string{ARISTA},
+			NoPageVars:     []*string{&AristaNoPage},
+			Prompts:        []string{`\n[\w.-]+(\(config[^)]*\))?[>#]\s*$`},
+			Privilege:      "enable",
+			ConfigEnter:    []string{"configure terminal"},
+			ConfigExit:     []string{"end"},
+			Save:           []string{"write memory"},
+			Errors:         []string{`% Invalid input`, `% Incomplete command`, `% Ambiguous command`, `% Unavailable command`, `% Not supported`},
+			Logout:         []string{"exit"},
+			Version:        "show version",
+			RunningConfig:  "show running-config",
+			StartupConfig:  "show startup-config",
+			BannerKeywords: []string{ARISTA},
+			Getters: map[string][]string{
+				GetterInterfaces: {"show interfaces description"},
+			},
+		},
+		BaseDriver{
+			Brand:          RUIJIE,
+			DetectKeywords: []string{RUIJIE, "rgos"},
+			NoPageVars:     []*string{&RuijieNoPage},
+			Prompts:        []string{`\n[\w.-]+(\(config[^)]*\))?[>#]\s*$`},
+			Privilege:      "enable",
+			ConfigEnter:    []string{"configure terminal"},
+			ConfigExit:     []string{"end"},
+			Save:           []string{"write"},
+			Errors:         []string{`% Invalid input detected`, `% Unknown command`, `% Incomplete command`, `% Ambiguous command`},
+			Logout:         []string{"exit"},
+			Version:        "show version",
+			RunningConfig:  "show running-config",
+			StartupConfig:  "show startup-config",
+			BannerKeywords: []string{RUIJIE, "rgos"},
+			Getters: map[string][]string{
+				GetterInterfaces: {"show interface status"},
+			},
+		},
+		BaseDriver{
+			Brand:          FORTINET,
+			DetectKeywords: []string{"fortigate", "fortios"},
+			NoPageVars:     []*string{&FortinetNoPage},
+			Prompts:        []string{`\n[\w.-]+( \([\w.-]+\))? [#$]\s*$`},
+			Errors:         []string{`Command fail\. Return code`, `Unknown action`, `command parse error`, `entry not found`},
+			Logout:         []string{"exit"},
+			Version:        "get system status",
+			RunningConfig:  "show",
+			BannerKeywords: []string{"fortigate", "fortios"},
+			ContextEnter:   []string{"config vdom", "edit %s"},
+			ContextExit:    []string{"end"},
+			ContextCheck:   []string{"config global", "diagnose sys vd list", "end"},
+			ContextExists:  `(?m)\bname=%s/`,
+		},
+		BaseDriver{
+			Brand:          PALOALTO,
+			DetectKeywords: []string{"model: pa-", "palo alto", "pan-os", "app-version:"},
+			NoPageVars:     []*string{&PaloAltoNoPage},
+			Prompts:        []string{`\n[\w.-]+@[\w.-]+(\([\w.-]+\))?[>#]\s*$`},
+			ConfigEnter:    []string{"configure"},
+			ConfigExit:     []string{"commit", "exit"},
+			//放弃候选配置后再退出，PAN-OS 8.1以前没有revert config，改为从运行配置加载
+			ConfigAbort:    []string{"revert config", "exit"},
+			AbortFallback:  map[string]string{"revert config": "load config from running-config.xml"},
+			Errors:         []string{`Unknown command:`, `Invalid syntax`, `Server error`, `^\s*Error:`},
+			Logout:         []string{"exit"},
+			Version:        "show system info",
+			RunningConfig:  "show config running",
+			BannerKeywords: []string{"palo alto", "pan-os"},
+			ContextEnter:   []string{"set system setting target-vsys %s"},
+			ContextExit:    []string{"set system setting target-vsys none"},
+		},
+		BaseDriver{
+			Brand:          HILLSTONE,
+			DetectKeywords: []string{HILLSTONE, "stoneos"},
+			NoPageVars:     []*string{&HillstoneNoPage},
+			Prompts:        []string{`\n[\w.-]+(\([\w.-]+\))?[>#]\s*$`},
+			ConfigEnter:    []string{"configure"},
+			ConfigExit:     []string{"end"},
+			Save:           []string{"save", "y"},
+			Errors:         []string{`^\s*Error:`, `% Unrecognized command`, `unrecognized keyword`, `% Incomplete command`},
+			Logout:         []string{"exit"},
+			Version:        "show version",
+			RunningConfig:  "show configuration",
+			BannerKeywords: []string{HILLSTONE, "stoneos"},
+			ContextEnter:   []string{"enter-vsys %s"},
+			ContextExit:    []string{"exit-vsys"},
+		},
+		BaseDriver{
+			Brand:          SANGFOR,
+			DetectKeywords: []string{SANGFOR},
+			NoPageVars:     []*string{&SangforNoPage},
+			Prompts:        []string{`\n[^!\n ]{3,30}[^ \n][#>]\s*$`},
+			Privilege:      "enable",
+			ConfigEnter:    []string{"configure terminal"},
+			ConfigExit:     []string{"end"},
+			Save:           []string{"write"},
+			Errors:         ciscoErrors,
+			Logout:         []string{"exit"},
+			Version:        "show version",
+			BannerKeywords: []string{SANGFOR},
+		},
+		BaseDriver{
+			Brand:          AnShi,
+			DetectKeywords: []string{AnShi, "fit mode", "fat mode"},
+			NoPageVars:     []*string{&AnshiNoPage},
+			Prompts:        []string{`\n[^!\n ]{3,30}[^ \n][#>]\s*$`},
+			Errors:         ciscoErrors,
+			Logout:         []string{"exit"},
+			Version:        "show version",
+			BannerKeywords: []string{AnShi},
+			Probes:         []string{"list mode"},
+		},
+		BaseDriver{
+			Brand:          LINUX,
+			DetectKeywords: []string{LINUX},
+			NoPageVars:     []*string{&LinuxNopage},
+			Prompts:        []string{`\n.*\[[^\]]{1,100}\][#$]\s*$`},
+			Privilege:      "sudo -i",
+			Errors:         []string{`command not found`, `No such file or directory`, `Permission denied`},
+			Logout:         []string{"exit"},
+			Version:        "uname -a",
+			BannerKeywords: []string{"ubuntu", "debian", "centos", "red hat", "rocky linux", "kylin"},
+		},
+		BaseDriver{
+			Brand:          DIPU,
+			DetectKeywords: []string{DIPU},
+			NoPageVars:     []*string{&DiPuNoPage},
+			Prompts:        []string{`\n<[^!]{1,100}>\s*$`, `\n\[[^\]]{1,100}\]\s*$`},
+			ConfigEnter:    []string{"conf-mode"},
+			ConfigExit:     []string{"end"},
+			Save:           []string{"write file"},
+			Errors:         []string{`% Unknown command`, `% Incomplete command`, `Error:`},
+			Logout:         []string{"exit"},
+			Version:        "show version",
+			RunningConfig:  "show running-config",
+			BannerKeywords: []string{DIPU},
+			Getters: map[string][]string{
+				GetterInterfaces:  {"show running-config", "show interface"},
+				GetterMACTable:    {"show mac-address"},
+				GetterEnvironment: {"show power"},
+			},
+		},
+		BaseDriver{
+			Brand:          ZTE,
+			DetectKeywords: []string{"current privilege", "zxr10", "zte corporation"},
+			NoPageVars:     []*string{&ZTENoPage},
+			Prompts:        []string{`\n[^!\n ]{3,30}[^ \n][#>]\s*$`},
+			Privilege:      "enable",
+			ConfigEnter:    []string{"configure terminal"},
+			ConfigExit:     []string{"end"},
+			Save:           []string{"write"},
+			Errors:         []string{`%Error`, `% Invalid input`, `% Incomplete command`, `% Ambiguous command`},
+			Logout:         []string{"exit"},
+			Version:        "show version",
+			RunningConfig:  "show running-config",
+			BannerKeywords: []string{"zte_ssh", "zxr10"},
+			Probes:         []string{"show version", "show privilege"},
+			Getters: map[string][]string{
+				GetterInterfaces:  {"show running-config", "show interface brief"},
+				GetterMACTable:    {"show mac"},
+				GetterEnvironment: {"show power"},
+			},
+		},
+		BaseDriver{
+			Brand:          CISCO,
+			DetectKeywords: []string{CISCO},
+			NoPageVars:     []*string{&CiscoNoPage},
+			Prompts:        []string{`\n[^!\n ]{3,30}[^ \n]#\s*$`, `\n[^!\n ]{3,30}[^ \n]>\s*$`},
+			Privilege:      "enable",
+			ConfigEnter:    []string{"configure terminal"},
+			ConfigExit:     []string{"end"},
+			Save:           []string{"write memory"},
+			Errors:         ciscoErrors,
+			Logout:         []string{"exit"},
+			Version:        "show version",
+			RunningConfig:  "show running-config",
+			StartupConfig:  "show startup-config",
+			BannerKeywords: []string{"cisco"},
+			Getters: map[string][]string{
+				GetterInterfaces:  {"show running-config interface", "show interfaces"},
+				GetterMACTable:    {"show mac address-table"},
+				GetterEnvironment: {"show environment power", "show environment fan", "show environment temperature"},
+				GetterNeighbors:   {"show lldp neighbors detail", "show cdp neighbors detail"},
+				GetterARPTable:    {"show ip arp"},
+				GetterRoutes:      {"show ip route"},
+			},
+			VRFKeyword: "vrf",
+			Transaction: TransactionCmds{
+				Checkpoint: []string{"copy running-config flash:arkssh_checkpoint.cfg"},
+				Rollback:   []string{"configure replace flash:arkssh_checkpoint.cfg force"},
+				//revert timer到期时恢复到进入配置模式前的运行配置，依赖archive功能，设备需配置archive path
+				ConfirmedEnter: []string{"configure terminal revert timer %d"},
+				Confirm:        []string{"configure confirm"},
+				Answers: []PromptAnswer{
+					{Cmd: "copy running-config", Prompt: `\]\?\s*$`},
+					{Cmd: "copy running-config", Prompt: `\[confirm\]`},
+				},
+			},
+		},
+	}
+	driverLocker      = new(sync.RWMutex)
+	promptRegexpCache = new(sync.Map)
+)
+
+/**
+ * 注册厂商Driver，同名的Driver会被替换；新的Driver排在已注册Driver之前，优先参与品牌识别
+ * @param	driver，实现了Driver接口的厂商
+ */
+func RegisterDriver(driver Driver) {
+	driverLocker.Lock()
+	defer driverLocker.Unlock()
+	for i := range driverRegistry {
+		if driverRegistry[i].Name() == driver.Name() {
+			driverRegistry[i] = driver
+			return
+		}
+	}
+	driverRegistry = append([]Driver{driver}, driverRegistry...)
+}
+
+/**
+ * 根据品牌名称获取Driver
+ * @param	brand，品牌名称
+ * @return  Driver，bool为false表示未注册
+ */
+func GetDriver(brand string) (Driver, bool) {
+	driverLocker.RLock()
+	defer driverLocker.RUnlock()
+	for _, driver := range driverRegistry {
+		if driver.Name() == brand {
+			return driver, true
+		}
+	}
+	return nil, false
+}
+
+/**
+ * 获取所有已注册的Driver，顺序即品牌识别的优先级
+ * @return  Driver切片的副本
+ */
+func Drivers() []Driver {
+	driverLocker.RLock()
+	defer driverLocker.RUnlock()
+	drivers := make([]Driver, len(driverRegistry))
+	copy(drivers, driverRegistry)
+	return drivers
+}
+
+/**
+ * 根据设备回显识别品牌，依次交给已注册的Driver判断
+ * @param	output，设备回显
+ * @return  识别到的Driver，bool为false表示无法识别
+ */
+func DetectDriver(output string) (Driver, bool) {
+	output = strings.ToLower(output)
+	for _, driver := range Drivers() {
+		if driver.Detect(output) {
+			return driver, true
+		}
+	}
+	return nil, false
+}
+
+/**
+ * 检查回显中是否包含Driver定义的错误信息
+ * @param	driver，厂商Driver，output，命令的回显
+ * @return  匹配到的错误行，bool为true表示命令执行失败
+ */
+func MatchDriverError(driver Driver, output string) (string, bool) {
+	if driver == nil {
+		return "", false
+	}
+	for _, pattern := range driver.ErrorPatterns() {
+		re := cachedRegexp(pattern)
+		if re == nil {
+			continue
+		}
+		for _, line := range strings.Split(output, "\n") {
+			if re.MatchString(line) {
+				return strings.TrimSpace(line), true
+			}
+		}
+	}
+	return "", false
+}
+
+/**
+ * 获取品牌对应的提示符正则：PROMPT与Driver的提示符合并，品牌未知时合并所有已注册Driver的提示符
+ * @param	brand，品牌名称
+ * @return  编译后的正则
+ */
+func promptRegexp(brand string) *regexp.Regexp {
+	patterns := []string{PROMPT}
+	if driver, ok := GetDriver(brand); ok {
+		patterns = append(patterns, driver.PromptPatterns()...)
+	} else {
+		for _, driver := range Drivers() {
+			patterns = append(patterns, driver.PromptPatterns()...)
+		}
+	}
+	pattern := strings.Join(patterns, "|")
+	if cache, ok := promptRegexpCache.Load(pattern); ok {
+		return cache.(*regexp.Regexp)
+	}
+	re, err := regexp.Compile(pattern)
+	if err != nil {
+		LogError("提示符正则编译失败:%s,brand:%s", err.Error(), brand)
+		re = regexp.MustCompile(PROMPT)
+	}
+	promptRegexpCache.Store(pattern, re)
+	return re
+}
+
+/**
+ * 获取当前会话品牌对应的Driver
+ * @return  Driver，品牌未知或未注册时为nil
+ */
+func (s *SSHSession) Driver() Driver {
+	driver, ok := GetDriver(s.brand)
+	if !ok {
+		return nil
+	}
+	return driver
+}
+
+/**
+ * 按Driver定义的提权命令提升权限（如cisco的enable，华为的super），需要密码时自动输入
+ * @param	secret，提权密码
+ * @return  提权失败时返回错误
+ */
+func (s *SSHSession) Escalate(secret string) error {
+	driver := s.Driver()
+	if driver == nil || driver.PrivilegeCmd() == "" {
+		return nil
+	}
+	s.WriteChannel(driver.PrivilegeCmd())
+	result := s.ReadChannelExpect(time.Second, "assword", "#", ">", "]")
+	if strings.Contains(strings.ToLower(result), "password") {
+		s.WriteChannel(secret)
+		result, _ = s.ReadChannelTiming(5)
+		//密码错误时设备会再次要求输入密码或直接拒绝
+		lower := strings.ToLower(result)
+		if strings.Contains(lower, "password") || strings.Contains(lower, "denied") {
+			return errors.New("提权失败，密码错误")
+		}
+	}
+	if line, failed := MatchDriverError(driver, result); failed {
+		return errors.New("提权失败:" + line)
+	}
+	return nil
+}
+
+/**
+ * 切换到指定的虚拟系统（fortinet的VDOM，paloalto/hillstone的vsys）
+ * @param	name，虚拟系统名称
+ * @return  品牌不支持虚拟系统或切换失败时返回错误
+ */
+func (s *SSHSession) EnterContext(name string) error {
+	driver := s.Driver()
+	if driver == nil || len(driver.ContextCmds(name)) == 0 {
+		return errors.New("当前品牌不支持切换虚拟系统:" + s.brand)
+	}
+	if err := s.checkContext(driver, name); err != nil {
+		return err
+	}
+	//逐条发送并检查回显，避免后一条命令的错误被漏掉
+	for _, cmd := range driver.ContextCmds(name) {
+		if _, _, err := s.sendConfigLine(driver, cmd, 5); err != nil {
+			s.ExitContext()
+			return fmt.Errorf("切换虚拟系统失败:%s,%v", cmd, err)
+		}
+	}
+	return nil
+}
+
+// 按ContextCheckCmds确认虚拟系统存在，检查命令全部发送（如fortinet需要end退出config global），再判断结果
+func (s *SSHSession) checkContext(driver Driver, name string) error {
+	cmds := driver.ContextCheckCmds()
+	if len(cmds) == 0 {
+		return nil
+	}
+	var (
+		output   strings.Builder
+		firstErr error
+	)
+	for _, cmd := range cmds {
+		res, _, err := s.sendConfigLine(driver, cmd, 5)
+		output.WriteString(res)
+		if err != nil && firstErr == nil {
+			firstErr = fmt.Errorf("检查虚拟系统失败:%s,%v", cmd, err)
+		}
+	}
+	if firstErr != nil {
+		return firstErr
+	}
+	re := cachedRegexp(driver.ContextExistsPattern(name))
+	if re == nil || !re.MatchString(output.String()) {
+		return errors.New("虚拟系统不存在:" + name)
+	}
+	return nil
+}
+
+/**
+ * 退出当前的虚拟系统
+ */
+func (s *SSHSession) ExitContext() {
+	driver := s.Driver()
+	if driver == nil {
+		return
+	}
+	for _, cmd := range driver.ContextExitCmds() {
+		s.WriteChannel(cmd)
+		s.ReadChannelTiming(5)
+	}
+}
+
+/**
+ * 按Driver定义的命令退出登录，之后应调用Close释放会话
+ */
+func (s *SSHSession) Logout() {
+	driver := s.Driver()
+	if driver == nil || len(driver.LogoutCmds()) == 0 {
+		return
+	}
+	s.WriteChannel(driver.LogoutCmds()...)
+	s.ReadChannelExpect(time.Second)
+}
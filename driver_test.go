@@ -0,0 +1,104 @@
+package arkssh
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestDetectDriver(t *testing.T) {
+	cases := map[string]string{
+		"Huawei Versatile Routing Platform Software":  HUAWEI,
+		"H3C Comware Software, Version 7.1.070":       H3C,
+		"Cisco IOS Software, C3750E Software":         CISCO,
+		"Current privilege level is 15":               ZTE,
+		"DPtech Firewall System Software Version 1.0": DIPU,
+		"Version: FortiGate-100F v7.0.12,build0523":   FORTINET,
+		"model: PA-3220\nsw-version: 10.1.6":          PALOALTO,
+		"Hillstone Networks StoneOS software":         HILLSTONE,
+		"JUNOS OS Kernel 64-bit [20210809.d7f6a5e]":   JUNIPER,
+		"Arista DCS-7050SX3-48YC8":                    ARISTA,
+		"Ruijie Networks Co., Ltd. RGOS 11.4":         RUIJIE,
+	}
+	for output, want := range cases {
+		driver, ok := DetectDriver(output)
+		if !ok || driver.Name() != want {
+			t.Errorf("DetectDriver(%q) got %v, want %s", output, driver, want)
+		}
+	}
+}
+
+func TestRegisterDriver(t *testing.T) {
+	RegisterDriver(BaseDriver{Brand: "acme", DetectKeywords: []string{"acme os"}})
+	driver, ok := DetectDriver("ACME OS Software, Cisco compatible CLI")
+	if !ok || driver.Name() != "acme" {
+		t.Fatalf("custom driver should be detected before cisco, got %v", driver)
+	}
+	if _, ok := GetDriver("acme"); !ok {
+		t.Fatalf("GetDriver should find registered driver")
+	}
+}
+
+func TestContextCmds(t *testing.T) {
+	driver, _ := GetDriver(FORTINET)
+	got := driver.ContextCmds("root")
+	if len(got) != 2 || got[0] != "config vdom" || got[1] != "edit root" {
+		t.Fatalf("ContextCmds()=%v", got)
+	}
+	driver, _ = GetDriver(HUAWEI)
+	if len(driver.ContextCmds("root")) != 0 {
+		t.Fatalf("huawei should not support context")
+	}
+}
+
+func TestEnterContext(t *testing.T) {
+	reply := func(cmd string) string {
+		switch cmd {
+		case "diagnose sys vd list":
+			return "list virtual firewall info:\r\nname=root/root index=0 enabled\r\nname=VDOM-A/VDOM-A index=1 enabled\r\nFG-01 (global) # "
+		case "config global":
+			return "\r\nFG-01 (global) # "
+		case "config vdom":
+			return "\r\nFG-01 (vdom) # "
+		case "edit VDOM-A":
+			return "current vf=VDOM-A:1\r\nFG-01 (VDOM-A) # "
+		default:
+			return "\r\nFG-01 # "
+		}
+	}
+	//不存在的VDOM不能执行edit，否则会被创建
+	s, received := newScriptedSession(FORTINET, reply)
+	if err := s.EnterContext("VDOM"); err == nil || !strings.Contains(err.Error(), "不存在") {
+		t.Fatalf("missing vdom err:%v", err)
+	}
+	want := []string{"config global", "diagnose sys vd list", "end"}
+	if strings.Join(*received, "|") != strings.Join(want, "|") {
+		t.Fatalf("sent %q, want %q", *received, want)
+	}
+	s, received = newScriptedSession(FORTINET, reply)
+	if err := s.EnterContext("VDOM-A"); err != nil {
+		t.Fatal(err)
+	}
+	want = append(want, "config vdom", "edit VDOM-A")
+	if strings.Join(*received, "|") != strings.Join(want, "|") {
+		t.Fatalf("sent %q, want %q", *received, want)
+	}
+}
+
+func TestNoPageCmdsRuntimeOverride(t *testing.T) {
+	defer func(origin string) { HuaweiNoPage = origin }(HuaweiNoPage)
+	HuaweiNoPage = "screen-length 512 temporary"
+	driver, _ := GetDriver(HUAWEI)
+	if got := driver.NoPageCmds(); len(got) != 1 || got[0] != "screen-length 512 temporary" {
+		t.Fatalf("NoPageCmds()=%v", got)
+	}
+	//fortinet默认不修改console配置，调用方显式开启后才发送
+	defer func(origin string) { FortinetNoPage = origin }(FortinetNoPage)
+	fortinet, _ := GetDriver(FORTINET)
+	if got := fortinet.NoPageCmds(); strings.Join(got, "") != "" {
+		t.Fatalf("fortinet default NoPageCmds()=%v", got)
+	}
+	FortinetNoPage = FortinetConsoleNoPage
+	if got := fortinet.NoPageCmds(); len(got) != 1 || !strings.Contains(got[0], "set output standard") {
+		t.Fatalf("fortinet opt-in NoPageCmds()=%v", got)
+	}
+}
@@ -0,0 +1,247 @@
+package arkssh
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// 电源、风扇、温度对应的textfsm模板名称
+const (
+	PowerSuppliesTemplate = "power_supplies" //每个电源一条记录；power_state模板保持每个槽位一条记录（power_1、power_2）的原有格式
+	FansTemplate          = "fans"
+	TemperatureTemplate   = "temperature"
+)
+
+var (
+	// 电源和风扇的正常状态（小写），不在其中的状态（Absent、NotSupply、Fault等）均视为异常
+	normalHardwareStates = map[string]bool{"normal": true, "supply": true, "ok": true, "good": true, "powered-up": true, "present": true}
+	// 温度传感器的正常状态（小写）
+	normalTemperatureStates = map[string]bool{"normal": true, "ok": true, "green": true}
+)
+
+/**
+ * 设备的硬件运行环境
+ * @attr Power:电源，Fans:风扇，Temperatures:温度传感器
+ */
+type Environment struct {
+	Power        []PowerSupply       `bson:"power,omitempty" json:"power,omitempty"`
+	Fans         []Fan               `bson:"fans,omitempty" json:"fans,omitempty"`
+	Temperatures []TemperatureSensor `bson:"temperatures,omitempty" json:"temperatures,omitempty"`
+}
+
+/**
+ * 电源模块
+ * @attr Slot:槽位（堆叠成员），ID:电源编号（如PWR1），Status:设备显示的状态，Abnormal:是否异常（不在位、未供电、故障等）
+ */
+type PowerSupply struct {
+	Slot     string `bson:"slot,omitempty" json:"slot,omitempty"`
+	ID       string `bson:"id" json:"id"`
+	Status   string `bson:"status" json:"status"`
+	Abnormal bool   `bson:"abnormal" json:"abnormal"`
+}
+
+/**
+ * 风扇
+ * @attr Slot:槽位，ID:风扇编号，Status:设备显示的状态，Speed:转速（华为为百分比，思科为RPM，设备未显示时为0），Abnormal:是否异常
+ */
+type Fan struct {
+	Slot     string `bson:"slot,omitempty" json:"slot,omitempty"`
+	ID       string `bson:"id" json:"id"`
+	Status   string `bson:"status" json:"status"`
+	Speed    int    `bson:"speed,omitempty" json:"speed,omitempty"`
+	Abnormal bool   `bson:"abnormal" json:"abnormal"`
+}
+
+/**
+ * 温度传感器，温度单位均为摄氏度
+ * @attr Slot:槽位，Sensor:传感器名称，Status:设备显示的状态，Current:当前温度，Lower:温度下限，Warning:告警阈值，Upper:严重告警阈值，
+ *       阈值为nil表示设备未显示（下限可能为0或负数），Abnormal:状态异常或温度超过阈值
+ */
+type TemperatureSensor struct {
+	Slot     string `bson:"slot,omitempty" json:"slot,omitempty"`
+	Sensor   string `bson:"sensor,omitempty" json:"sensor,omitempty"`
+	Status   string `bson:"status,omitempty" json:"status,omitempty"`
+	Current  int    `bson:"current" json:"current"`
+	Lower    *int   `bson:"lower,omitempty" json:"lower,omitempty"`
+	Warning  *int   `bson:"warning,omitempty" json:"warning,omitempty"`
+	Upper    *int   `bson:"upper,omitempty" json:"upper,omitempty"`
+	Abnormal bool   `bson:"abnormal" json:"abnormal"`
+}
+
+// power_supplies模板的一条记录，每个电源一条
+type powerSupplyRecord struct {
+	SlotID  string `textfsm:"slot_id"`
+	PowerID string `textfsm:"power_id"`
+	State   string `textfsm:"state"`
+}
+
+// fans模板的一条记录
+type fanRecord struct {
+	Slot   string `textfsm:"slot"`
+	FanID  string `textfsm:"fan_id"`
+	Status string `textfsm:"status"`
+	Speed  int    `textfsm:"speed"`
+}
+
+// temperature模板的一条记录，阈值可能为NA，先按字符串读取
+type temperatureRecord struct {
+	Slot    string `textfsm:"slot"`
+	Sensor  string `textfsm:"sensor"`
+	Status  string `textfsm:"status"`
+	Current int    `textfsm:"current"`
+	Lower   string `textfsm:"lower"`
+	Warning string `textfsm:"warning"`
+	Upper   string `textfsm:"upper"`
+}
+
+/**
+ * 外部调用的统一方法，获取设备电源、风扇和温度的状态
+ * @return 硬件运行环境和执行错误
+ */
+func (d *Device) GetEnvironment() (*Environment, error) {
+	var env *Environment
+	err := d.withSession(func(s *SSHSession) error {
+		var err error
+		env, err = s.GetEnvironment(d.getterTimeout())
+		return err
+	})
+	if err != nil {
+		LogError("获取硬件运行环境错误:%s,IP:%s", err.Error(), d.IP)
+		return nil, err
+	}
+	LogDebug("获取硬件运行环境成功,IP:%s,异常项数量:%d", d.IP, len(env.Alarms()))
+	return env, nil
+}
+
+/**
+ * 获取当前SSH到的设备的硬件运行环境，执行的命令由Driver的GetterEnvironment定义，部分品牌只支持电源
+ * @param	timeout，读取单条命令回显的超时时间（秒）
+ * @return 硬件运行环境和执行错误
+ */
+func (s *SSHSession) GetEnvironment(timeout int) (*Environment, error) {
+	results, err := s.runGetter(GetterEnvironment, timeout)
+	if err != nil {
+		return nil, err
+	}
+	return BuildEnvironment(results)
+}
+
+/**
+ * 将power_supplies、fans、temperature模板的解析结果转换为硬件运行环境，并标记异常项
+ * @param	results，以模板名称为key的解析结果
+ * @return  硬件运行环境和转换错误
+ */
+func BuildEnvironment(results map[string][]map[string]interface{}) (*Environment, error) {
+	env := &Environment{}
+	powers, _, err := ParseInto[powerSupplyRecord](results[PowerSuppliesTemplate])
+	if err != nil {
+		return nil, err
+	}
+	for _, power := range powers {
+		if power.State == "" {
+			continue
+		}
+		env.Power = append(env.Power, PowerSupply{
+			Slot:     power.SlotID,
+			ID:       powerSupplyID(power.PowerID),
+			Status:   power.State,
+			Abnormal: !normalHardwareStates[strings.ToLower(power.State)],
+		})
+	}
+	fans, _, err := ParseInto[fanRecord](results[FansTemplate])
+	if err != nil {
+		return nil, err
+	}
+	for _, fan := range fans {
+		env.Fans = append(env.Fans, Fan{
+			Slot:     fan.Slot,
+			ID:       fan.FanID,
+			Status:   fan.Status,
+			Speed:    fan.Speed,
+			Abnormal: !normalHardwareStates[strings.ToLower(fan.Status)],
+		})
+	}
+	temperatures, _, err := ParseInto[temperatureRecord](results[TemperatureTemplate])
+	if err != nil {
+		return nil, err
+	}
+	for _, temperature := range temperatures {
+		sensor := TemperatureSensor{
+			Slot:    temperature.Slot,
+			Sensor:  temperature.Sensor,
+			Status:  temperature.Status,
+			Current: temperature.Current,
+			Lower:   thresholdValue(temperature.Lower),
+			Warning: thresholdValue(temperature.Warning),
+			Upper:   thresholdValue(temperature.Upper),
+		}
+		sensor.Abnormal = temperatureAbnormal(sensor)
+		env.Temperatures = append(env.Temperatures, sensor)
+	}
+	return env, nil
+}
+
+// 统一电源编号：纯数字的编号（华三、中兴等）补全为PWR1，思科堆叠的A/B依次对应PWR1/PWR2，其余保持设备显示的编号
+func powerSupplyID(id string) string {
+	if _, err := strconv.Atoi(id); err == nil {
+		return "PWR" + id
+	}
+	if len(id) == 1 && id[0] >= 'A' && id[0] <= 'Z' {
+		return "PWR" + strconv.Itoa(int(id[0]-'A')+1)
+	}
+	return id
+}
+
+// 阈值为NA或为空时返回nil
+func thresholdValue(text string) *int {
+	n, err := strconv.Atoi(strings.TrimSpace(text))
+	if err != nil {
+		return nil
+	}
+	return &n
+}
+
+// 状态异常、达到告警阈值或低于下限均视为异常，设备未显示的阈值不参与判断
+func temperatureAbnormal(sensor TemperatureSensor) bool {
+	switch {
+	case sensor.Status != "" && !normalTemperatureStates[strings.ToLower(sensor.Status)]:
+		return true
+	case sensor.Warning != nil && sensor.Current >= *sensor.Warning:
+		return true
+	case sensor.Upper != nil && sensor.Current >= *sensor.Upper:
+		return true
+	case sensor.Lower != nil && sensor.Current < *sensor.Lower:
+		return true
+	}
+	return false
+}
+
+/**
+ * 汇总所有异常项的描述，便于直接用于告警
+ * @return  异常项描述，如"slot 1 PWR2:Absent"，没有异常时为空
+ */
+func (e *Environment) Alarms() []string {
+	alarms := make([]string, 0)
+	for _, power := range e.Power {
+		if power.Abnormal {
+			alarms = append(alarms, fmt.Sprintf("slot %s %s:%s", power.Slot, power.ID, power.Status))
+		}
+	}
+	for _, fan := range e.Fans {
+		if fan.Abnormal {
+			alarms = append(alarms, fmt.Sprintf("slot %s fan %s:%s", fan.Slot, fan.ID, fan.Status))
+		}
+	}
+	for _, sensor := range e.Temperatures {
+		if sensor.Abnormal {
+			alarms = append(alarms, fmt.Sprintf("slot %s sensor %s:%d℃", sensor.Slot, sensor.Sensor, sensor.Current))
+		}
+	}
+	return alarms
+}
+
+// Abnormal 是否存在任意异常项
+func (e *Environment) Abnormal() bool {
+	return len(e.Alarms()) > 0
+}
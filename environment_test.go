@@ -0,0 +1,97 @@
+package arkssh
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestBuildEnvironment(t *testing.T) {
+	env, err := BuildEnvironment(map[string][]map[string]interface{}{
+		PowerSuppliesTemplate: fixtureRecords(t, HUAWEI, PowerSuppliesTemplate, "s5731"),
+		FansTemplate:          fixtureRecords(t, HUAWEI, FansTemplate, "ce"),
+		TemperatureTemplate:   fixtureRecords(t, HUAWEI, TemperatureTemplate, "s5700"),
+	})
+	if err != nil {
+		t.Fatalf("BuildEnvironment err:%v", err)
+	}
+	wantPower := []PowerSupply{
+		{Slot: "0", ID: "PWR1", Status: "Supply"},
+		{Slot: "0", ID: "PWR2", Status: "NotSupply", Abnormal: true},
+	}
+	if !reflect.DeepEqual(env.Power, wantPower) {
+		t.Fatalf("power got %+v", env.Power)
+	}
+	if len(env.Fans) != 3 || env.Fans[0].Abnormal || env.Fans[0].Speed != 50 || !env.Fans[1].Abnormal || !env.Fans[2].Abnormal {
+		t.Fatalf("fans got %+v", env.Fans)
+	}
+	if len(env.Temperatures) != 2 || env.Temperatures[0].Abnormal || !env.Temperatures[1].Abnormal || env.Temperatures[1].Upper == nil || *env.Temperatures[1].Upper != 75 {
+		t.Fatalf("temperatures got %+v", env.Temperatures)
+	}
+	want := []string{"slot 0 PWR2:NotSupply", "slot 1 fan 2:Abnormal", "slot 1 fan 3:Absent", "slot 0 sensor 2:78℃"}
+	if alarms := env.Alarms(); !reflect.DeepEqual(alarms, want) || !env.Abnormal() {
+		t.Fatalf("alarms got %v", alarms)
+	}
+}
+
+func TestBuildEnvironmentStackPower(t *testing.T) {
+	//堆叠成员的电源数量不固定，每个电源一项
+	env, err := BuildEnvironment(map[string][]map[string]interface{}{
+		PowerSuppliesTemplate: fixtureRecords(t, HUAWEI, PowerSuppliesTemplate, "s5731_stack"),
+	})
+	if err != nil {
+		t.Fatalf("BuildEnvironment err:%v", err)
+	}
+	want := []string{"slot 0 PWR2:Absent", "slot 1 PWR2:NotSupply"}
+	if len(env.Power) != 5 || env.Power[4].Slot != "2" || env.Power[4].ID != "PWR1" || !reflect.DeepEqual(env.Alarms(), want) {
+		t.Fatalf("power got %+v, alarms %v", env.Power, env.Alarms())
+	}
+	//思科堆叠的A/B电源对应PWR1/PWR2
+	env, err = BuildEnvironment(map[string][]map[string]interface{}{
+		PowerSuppliesTemplate: fixtureRecords(t, CISCO, PowerSuppliesTemplate, "ios_stack"),
+	})
+	if err != nil || len(env.Power) != 4 || env.Power[3].Slot != "2" || env.Power[3].ID != "PWR2" || !env.Power[3].Abnormal {
+		t.Fatalf("cisco power got %+v,%v", env.Power, err)
+	}
+}
+
+func TestTemperatureAbnormal(t *testing.T) {
+	zero, minus := 0, -10
+	cases := []struct {
+		name   string
+		sensor TemperatureSensor
+		want   bool
+	}{
+		{"未显示下限", TemperatureSensor{Current: -5}, false},
+		{"低于下限0", TemperatureSensor{Current: -5, Lower: &zero}, true},
+		{"高于负数下限", TemperatureSensor{Current: -5, Lower: &minus}, false},
+		{"状态异常", TemperatureSensor{Current: 30, Status: "ABNORMAL"}, true},
+	}
+	for _, c := range cases {
+		if got := temperatureAbnormal(c.sensor); got != c.want {
+			t.Errorf("%s:temperatureAbnormal()=%v", c.name, got)
+		}
+	}
+}
+
+func TestTemperatureThreshold(t *testing.T) {
+	//华三没有状态列，只能通过阈值判断
+	env, err := BuildEnvironment(map[string][]map[string]interface{}{
+		TemperatureTemplate: fixtureRecords(t, H3C, TemperatureTemplate, "switch"),
+	})
+	if err != nil {
+		t.Fatalf("BuildEnvironment err:%v", err)
+	}
+	var abnormal []string
+	for _, sensor := range env.Temperatures {
+		if sensor.Abnormal {
+			abnormal = append(abnormal, sensor.Sensor)
+		}
+	}
+	if !reflect.DeepEqual(abnormal, []string{"outflow 1"}) {
+		t.Fatalf("abnormal sensors got %v", abnormal)
+	}
+	env, _ = BuildEnvironment(nil)
+	if env.Abnormal() {
+		t.Fatalf("empty environment should not be abnormal")
+	}
+}
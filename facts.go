@@ -0,0 +1,126 @@
+package arkssh
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// 设备基础信息对应的textfsm模板名称
+const FactsTemplate = "facts"
+
+/**
+ * 设备的基础信息，由版本信息和序列号信息解析得到
+ * @attr Vendor:品牌，Model:型号（如S6720-54C-EI-48S-AC、CE6881-48S6CQ、S6850-56HF），Version:软件版本，Patch:补丁版本，Uptime:运行时间，Serial:序列号，Hostname:主机名
+ */
+type Facts struct {
+	Vendor   string `bson:"vendor,omitempty" json:"vendor,omitempty"`
+	Model    string `bson:"model,omitempty" json:"model,omitempty"`
+	Version  string `bson:"version,omitempty" json:"version,omitempty"`
+	Patch    string `bson:"patch,omitempty" json:"patch,omitempty"`
+	Uptime   string `bson:"uptime,omitempty" json:"uptime,omitempty"`
+	Serial   string `bson:"serial,omitempty" json:"serial,omitempty"`
+	Hostname string `bson:"hostname,omitempty" json:"hostname,omitempty"`
+}
+
+/**
+ * 外部调用的统一方法，获取设备的型号、版本、序列号等基础信息，结果同时写入d.Facts
+ * @return 设备基础信息和执行错误
+ */
+func (d *Device) GetFacts() (*Facts, error) {
+	var facts *Facts
+	err := d.withSession(func(s *SSHSession) error {
+		var err error
+		facts, err = s.GetFacts()
+		return err
+	})
+	if err != nil {
+		LogError("获取设备基础信息错误:%s,IP:%s", err.Error(), d.IP)
+		return nil, err
+	}
+	d.Facts = facts
+	d.Brand = facts.Vendor
+	LogDebug("获取设备基础信息成功,IP:%s,model:%s,version:%s", d.IP, facts.Model, facts.Version)
+	return facts, nil
+}
+
+/**
+ * 获取当前SSH到的设备的基础信息，结果缓存在会话上，同一会话只采集一次
+ * 识别品牌时采集到的版本信息会被复用，不再重复执行版本命令
+ * @return 设备基础信息和执行错误
+ */
+func (s *SSHSession) GetFacts() (*Facts, error) {
+	if s.facts != nil {
+		return s.facts, nil
+	}
+	brand := s.GetSSHBrand()
+	driver := s.Driver()
+	if driver == nil {
+		return nil, errors.New("无法识别设备品牌，不能获取设备基础信息")
+	}
+	//没有facts模板的品牌直接返回错误，不发送命令
+	if err := checkFactsTemplate(brand); err != nil {
+		return nil, err
+	}
+	output := s.versionOutput
+	if output == "" && driver.VersionCmd() != "" {
+		s.WriteChannel(driver.VersionCmd())
+		output, _ = s.ReadChannelTiming(10)
+	}
+	for _, cmd := range driver.InventoryCmds() {
+		s.WriteChannel(cmd)
+		res, _ := s.ReadChannelTiming(10)
+		output += res
+	}
+	facts, err := ParseFacts(brand, output)
+	if err != nil {
+		return nil, err
+	}
+	s.facts = facts
+	return facts, nil
+}
+
+/**
+ * 通过品牌对应的facts模板解析版本和序列号信息
+ * @param	brand，品牌名称，output，版本和序列号命令的回显
+ * @return  设备基础信息和解析错误
+ */
+func ParseFacts(brand, output string) (*Facts, error) {
+	if err := checkFactsTemplate(brand); err != nil {
+		return nil, err
+	}
+	res, err := TextFsmParseViaTemplateFile(brand, output, FactsTemplate)
+	if err != nil {
+		return nil, fmt.Errorf("解析设备基础信息失败,brand:%s,err:%v", brand, err)
+	}
+	record := res[0]
+	return &Facts{
+		Vendor:   brand,
+		Model:    recordString(record, "model"),
+		Version:  recordString(record, "version"),
+		Patch:    recordString(record, "patch"),
+		Uptime:   recordString(record, "uptime"),
+		Serial:   recordString(record, "serial"),
+		Hostname: recordString(record, "hostname"),
+	}, nil
+}
+
+// 内置的facts模板只有huawei、h3c、cisco，其余品牌可通过SetTemplateOverrideDir补充
+func checkFactsTemplate(brand string) error {
+	if _, err := ReadTemplate(brand, FactsTemplate); err != nil {
+		return fmt.Errorf("品牌%s不支持获取设备基础信息，缺少%s模板", brand, FactsTemplate)
+	}
+	return nil
+}
+
+// 获取textfsm解析结果中的字符串字段，List类型的字段用空格拼接
+func recordString(record map[string]interface{}, key string) string {
+	switch v := record[key].(type) {
+	case string:
+		return strings.TrimSpace(v)
+	case []string:
+		return strings.TrimSpace(strings.Join(v, " "))
+	default:
+		return ""
+	}
+}
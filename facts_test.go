@@ -0,0 +1,69 @@
+package arkssh
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestParseFacts(t *testing.T) {
+	huawei := `<CE-01>display version
+Huawei Versatile Routing Platform Software
+VRP (R) software, Version 8.180 (CE6881 V200R005C20SPC800)
+Copyright (C) 2012-2019 Huawei Technologies Co., Ltd.
+HUAWEI CE6881-48S6CQ uptime is 10 days, 2 hours, 5 minutes
+Patch Version: V200R005SPH020
+<CE-01>display esn
+ESN of slot 1: 2102351931P0K8000123
+ESN of slot 2: 2102351931P0K8000456
+<CE-01>`
+	h3c := `<S6850-01>display version
+H3C Comware Software, Version 7.1.070, Release 6616P05
+Copyright (c) 2004-2021 New H3C Technologies Co., Ltd. All rights reserved.
+H3C S6850-56HF uptime is 0 weeks, 5 days, 3 hours, 2 minutes
+Last reboot reason : Cold reboot
+<S6850-01>display device manuinfo
+Slot 1 CPU 0:
+DEVICE_NAME          : S6850-56HF
+DEVICE_SERIAL_NUMBER : 210235A2CSH123000012
+MAC_ADDRESS          : 3C8C-4012-0000
+Fan 1:
+DEVICE_SERIAL_NUMBER : 210231A0GYH123000099
+<S6850-01>`
+	cisco := `R1#show version
+Cisco IOS Software, C3750E Software (C3750E-UNIVERSALK9-M), Version 15.0(2)SE11, RELEASE SOFTWARE (fc3)
+R1 uptime is 1 year, 2 weeks, 3 days, 4 hours, 5 minutes
+cisco WS-C3750X-48P (PowerPC405) processor (revision A0) with 262144K bytes of memory.
+System serial number            : FDO1234X0AB
+R1#`
+	cases := []struct {
+		brand, output string
+		want          Facts
+	}{
+		{HUAWEI, huawei, Facts{Vendor: HUAWEI, Model: "CE6881-48S6CQ", Version: "V200R005C20SPC800", Patch: "V200R005SPH020",
+			Uptime: "10 days, 2 hours, 5 minutes", Serial: "2102351931P0K8000123", Hostname: "CE-01"}},
+		{H3C, h3c, Facts{Vendor: H3C, Model: "S6850-56HF", Version: "7.1.070", Patch: "6616P05",
+			Uptime: "0 weeks, 5 days, 3 hours, 2 minutes", Serial: "210235A2CSH123000012", Hostname: "S6850-01"}},
+		{CISCO, cisco, Facts{Vendor: CISCO, Model: "WS-C3750X-48P", Version: "15.0(2)SE11", Patch: "fc3",
+			Uptime: "1 year, 2 weeks, 3 days, 4 hours, 5 minutes", Serial: "FDO1234X0AB", Hostname: "R1"}},
+	}
+	for _, c := range cases {
+		got, err := ParseFacts(c.brand, c.output)
+		if err != nil {
+			t.Fatalf("ParseFacts(%s) err:%v", c.brand, err)
+		}
+		if *got != c.want {
+			t.Errorf("ParseFacts(%s)=%+v, want %+v", c.brand, *got, c.want)
+		}
+	}
+}
+
+func TestGetFactsUnsupportedBrand(t *testing.T) {
+	if _, err := ParseFacts(JUNIPER, "JUNOS OS Kernel 64-bit"); err == nil || !strings.Contains(err.Error(), "不支持") {
+		t.Fatalf("ParseFacts(juniper) err:%v", err)
+	}
+	//没有模板时不发送版本命令
+	s, received := newScriptedSession(FORTINET, func(string) string { return "\r\nFW-01 # " })
+	if _, err := s.GetFacts(); err == nil || len(*received) != 0 {
+		t.Fatalf("GetFacts(fortinet) err:%v, sent:%q", err, *received)
+	}
+}
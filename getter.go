@@ -0,0 +1,145 @@
+package arkssh
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/sirikothe/gotextfsm"
+)
+
+// 结构化采集的名称，对应BaseDriver.Getters的key
+const (
+	GetterInterfaces  = "interfaces"
+	GetterMACTable    = "mac_table"
+	GetterEnvironment = "environment"
+	GetterHAStatus    = "ha_status"
+	GetterNeighbors   = "neighbors"
+	GetterARPTable    = "arp_table"
+	GetterRoutes      = "routes"
+	GetterMLAGGroups  = "mlag_groups" //M-LAG组编号，用于拼接GetterHAStatus中带%s的命令（如华为的display dfs-group %s m-lag）
+)
+
+// 结构化采集读取单条命令回显的默认超时时间（秒），Device.Timeout不为0时以其为准
+const defaultGetterTimeout = 30
+
+/**
+ * 获取设备的会话并执行fn，调用期间会话被锁定；需要提权或切换虚拟系统的设备先完成提权和切换
+ * @param	fn，使用会话执行的操作
+ * @return  获取会话、提权、切换虚拟系统或fn返回的错误
+ */
+func (d *Device) withSession(fn func(s *SSHSession) error) error {
+	if d.Port == "" {
+		d.Port = "22"
+	}
+	ipPort := d.IP + ":" + d.Port
+	sessionKey := d.Username + "_" + d.Password + "_" + ipPort
+	sessionManager.LockSession(sessionKey)
+	defer sessionManager.UnlockSession(sessionKey)
+
+	sshSession, err := sessionManager.GetSession(d.Username, d.Password, ipPort, d.Brand)
+	if err != nil {
+		LogError("获取会话错误:%s", err)
+		return err
+	}
+	//未指定品牌时使用登录时识别到的品牌，结果中的Brand（如MACLocation）才有值
+	if d.Brand == "" {
+		d.Brand = sshSession.GetSSHBrand()
+	}
+	if d.EnablePassword != "" {
+		if err := sshSession.Escalate(d.EnablePassword); err != nil {
+			LogError("提权错误:%s,IP:%s", err.Error(), d.IP)
+			return err
+		}
+	}
+	if d.Context != "" {
+		if err := sshSession.EnterContext(d.Context); err != nil {
+			LogError("切换虚拟系统错误:%s,IP:%s", err.Error(), d.IP)
+			return err
+		}
+		defer sshSession.ExitContext()
+	}
+	return fn(sshSession)
+}
+
+// 结构化采集读取回显的超时时间
+func (d *Device) getterTimeout() int {
+	if d.Timeout != 0 {
+		return d.Timeout
+	}
+	return defaultGetterTimeout
+}
+
+/**
+ * 执行结构化采集的命令，每条命令的回显按模板索引找到对应模板后解析
+ * @param	name，采集名称（如GetterInterfaces），timeout，读取单条命令回显的超时时间（秒）
+ * @return  以模板名称为key的解析结果，使用同一模板的多条命令结果合并在一起
+ */
+func (s *SSHSession) runGetter(name string, timeout int) (map[string][]map[string]interface{}, error) {
+	return s.runGetterInVRF(name, "", timeout)
+}
+
+/**
+ * 在指定的VPN实例/VRF中执行结构化采集的命令，命令按Driver的VRFCmd追加VRF
+ * @param	name，采集名称（如GetterRoutes），vrf，VPN实例/VRF名称，为空时与runGetter相同，timeout，读取单条命令回显的超时时间（秒）
+ * @return  以模板名称为key的解析结果，品牌不支持指定VRF时返回错误
+ */
+func (s *SSHSession) runGetterInVRF(name, vrf string, timeout int) (map[string][]map[string]interface{}, error) {
+	brand := s.GetSSHBrand()
+	driver := s.Driver()
+	if driver == nil {
+		return nil, errors.New("无法识别设备品牌，不能进行结构化采集")
+	}
+	cmds := driver.GetterCmds(name)
+	if len(cmds) == 0 {
+		return nil, fmt.Errorf("品牌%s不支持%s采集", brand, name)
+	}
+	return s.runGetterCmds(driver, cmds, vrf, timeout)
+}
+
+// 依次执行采集命令并按模板索引解析，结果以模板名称为key合并
+func (s *SSHSession) runGetterCmds(driver Driver, cmds []string, vrf string, timeout int) (map[string][]map[string]interface{}, error) {
+	brand := s.GetSSHBrand()
+	results := make(map[string][]map[string]interface{})
+	for _, cmd := range cmds {
+		if vrf != "" {
+			vrfCmd := driver.VRFCmd(cmd, vrf)
+			if vrfCmd == cmd {
+				return nil, fmt.Errorf("品牌%s不支持指定VRF", brand)
+			}
+			cmd = vrfCmd
+		}
+		templateName, found := LookupTemplate(brand, cmd)
+		if !found {
+			return nil, fmt.Errorf("模板索引中没有命令对应的模板,brand:%s,cmd:%s", brand, cmd)
+		}
+		sendCmd := driver.FormatCmd(cmd)
+		s.WriteChannel(sendCmd)
+		output, ok := s.ReadChannelTiming(timeout)
+		if !ok && output == "" {
+			return nil, fmt.Errorf("读取命令回显超时:%s", sendCmd)
+		}
+		records, err := parseTemplateRecords(brand, filterResult(output, sendCmd, nil), templateName)
+		if err != nil {
+			return nil, err
+		}
+		results[templateName] = append(results[templateName], records...)
+	}
+	return results, nil
+}
+
+/**
+ * 使用品牌的模板解析文本，与TextFsmParseViaTemplateFile不同，解析结果为空时不返回错误（如设备没有任何MAC表项）
+ * @param	brand，品牌名称，text，待解析的文本，templateName，模板名称
+ * @return  解析结果和模板错误
+ */
+func parseTemplateRecords(brand, text, templateName string) ([]map[string]interface{}, error) {
+	fsm, err := compiledTemplate(brand, templateName)
+	if err != nil {
+		return nil, err
+	}
+	parser := gotextfsm.ParserOutput{}
+	if err := parser.ParseTextString(text, fsm, true); err != nil {
+		return nil, fmt.Errorf("textfsm解析失败,brand:%s,template:%s,err:%v", brand, templateName, err)
+	}
+	return parser.Dict, nil
+}
@@ -3,7 +3,7 @@ module github.com/garygagaga/ark-ssh
 go 1.20
 
 require (
-	github.com/sirikothe/gotextfsm v1.0.0
+	github.com/sirikothe/gotextfsm v1.2.0
 	golang.org/x/crypto v0.14.0
 )
 
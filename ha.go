@@ -0,0 +1,296 @@
+package arkssh
+
+import (
+	"fmt"
+	"strings"
+)
+
+// 堆叠、M-LAG、VRRP对应的textfsm模板名称
+const (
+	StackMembersTemplate = "stack_members"
+	MLAGTemplate         = "mlag"
+	MLAGGroupsTemplate   = "dfs_groups"
+	VRRPTemplate         = "vrrp"
+)
+
+// 堆叠成员的角色
+const (
+	StackRoleMaster  = "master"
+	StackRoleStandby = "standby"
+	StackRoleSlave   = "slave"
+)
+
+var (
+	// 心跳（keepalive）和peer-link的正常状态（小写）
+	normalLinkStates = map[string]bool{"ok": true, "up": true}
+	// VRRP备份组的正常状态（小写），Initialize等状态表示备份组未生效
+	normalVRRPStates = map[string]bool{"master": true, "backup": true}
+)
+
+/**
+ * 设备的高可用状态，未配置堆叠、M-LAG或VRRP时对应字段为空
+ * @attr StackMembers:堆叠（IRF）成员，MLAG:M-LAG（华为DFS组）状态，VRRP:VRRP备份组
+ */
+type HAStatus struct {
+	StackMembers []StackMember `bson:"stack_members,omitempty" json:"stack_members,omitempty"`
+	MLAG         *MLAGStatus   `bson:"mlag,omitempty" json:"mlag,omitempty"`
+	VRRP         []VRRPGroup   `bson:"vrrp,omitempty" json:"vrrp,omitempty"`
+}
+
+/**
+ * 堆叠成员
+ * @attr ID:成员编号，Role:角色（master/standby/slave，华三IRF加入过程中为loading），Priority:优先级，MAC:小写冒号分隔的MAC地址，DeviceType:设备型号，设备未显示时为空
+ */
+type StackMember struct {
+	ID         int    `bson:"id" json:"id"`
+	Role       string `bson:"role" json:"role"`
+	Priority   int    `bson:"priority" json:"priority"`
+	MAC        string `bson:"mac,omitempty" json:"mac,omitempty"`
+	DeviceType string `bson:"device_type,omitempty" json:"device_type,omitempty"`
+}
+
+/**
+ * 本端的M-LAG状态，状态统一为小写
+ * @attr GroupID:华为DFS组编号，LocalRole:本端角色（华为为master/backup，华三为primary/secondary），Priority:本端优先级，
+ *       PeerLink:peer-link接口，PeerLinkStatus:peer-link状态，KeepaliveStatus:心跳状态
+ */
+type MLAGStatus struct {
+	GroupID         int    `bson:"group_id,omitempty" json:"group_id,omitempty"`
+	LocalRole       string `bson:"local_role,omitempty" json:"local_role,omitempty"`
+	Priority        int    `bson:"priority,omitempty" json:"priority,omitempty"`
+	PeerLink        string `bson:"peer_link,omitempty" json:"peer_link,omitempty"`
+	PeerLinkStatus  string `bson:"peer_link_status,omitempty" json:"peer_link_status,omitempty"`
+	KeepaliveStatus string `bson:"keepalive_status,omitempty" json:"keepalive_status,omitempty"`
+}
+
+/**
+ * VRRP备份组
+ * @attr VRID:备份组编号，Interface:规范化的接口名，State:状态（master/backup/initialize），VirtualIP:虚拟IP，Priority:运行优先级，设备未显示时为0
+ */
+type VRRPGroup struct {
+	VRID      int    `bson:"vrid" json:"vrid"`
+	Interface string `bson:"interface" json:"interface"`
+	State     string `bson:"state" json:"state"`
+	VirtualIP string `bson:"virtual_ip" json:"virtual_ip"`
+	Priority  int    `bson:"priority,omitempty" json:"priority,omitempty"`
+}
+
+// stack_members模板的一条记录
+type stackMemberRecord struct {
+	MemberID   int    `textfsm:"member_id"`
+	Role       string `textfsm:"role"`
+	MAC        string `textfsm:"mac"`
+	Priority   int    `textfsm:"priority"`
+	DeviceType string `textfsm:"device_type"`
+}
+
+// mlag模板的一条记录，华三的summary和role两条命令各产生一条记录
+type mlagRecord struct {
+	DfsGroupID      int    `textfsm:"dfs_group_id"`
+	LocalRole       string `textfsm:"local_role"`
+	Priority        int    `textfsm:"priority"`
+	PeerLink        string `textfsm:"peer_link"`
+	PeerLinkStatus  string `textfsm:"peer_link_status"`
+	KeepaliveStatus string `textfsm:"keepalive_status"`
+}
+
+// dfs_groups模板的一条记录
+type mlagGroupRecord struct {
+	GroupID string `textfsm:"dfs_group_id"`
+}
+
+// vrrp模板的一条记录
+type vrrpRecord struct {
+	VRID      int    `textfsm:"vrid"`
+	State     string `textfsm:"state"`
+	Interface string `textfsm:"interface"`
+	VirtualIP string `textfsm:"virtual_ip"`
+	Priority  int    `textfsm:"priority"`
+}
+
+/**
+ * 外部调用的统一方法，获取设备的堆叠、M-LAG和VRRP状态
+ * @return 高可用状态和执行错误
+ */
+func (d *Device) GetHAStatus() (*HAStatus, error) {
+	var status *HAStatus
+	err := d.withSession(func(s *SSHSession) error {
+		var err error
+		status, err = s.GetHAStatus(d.getterTimeout())
+		return err
+	})
+	if err != nil {
+		LogError("获取高可用状态错误:%s,IP:%s", err.Error(), d.IP)
+		return nil, err
+	}
+	LogDebug("获取高可用状态成功,IP:%s,异常项数量:%d", d.IP, len(status.Warnings()))
+	return status, nil
+}
+
+/**
+ * 获取当前SSH到的设备的高可用状态，执行的命令由Driver的GetterHAStatus定义，未配置的特性命令回显为错误提示，解析结果为空
+ * @param	timeout，读取单条命令回显的超时时间（秒）
+ * @return 高可用状态和执行错误
+ */
+func (s *SSHSession) GetHAStatus(timeout int) (*HAStatus, error) {
+	driver := s.Driver()
+	if driver == nil || len(driver.GetterCmds(GetterHAStatus)) == 0 {
+		//由runGetter返回无法识别品牌或不支持采集的错误
+		_, err := s.runGetter(GetterHAStatus, timeout)
+		return nil, err
+	}
+	cmds, err := s.haStatusCmds(driver, timeout)
+	if err != nil {
+		return nil, err
+	}
+	results, err := s.runGetterCmds(driver, cmds, "", timeout)
+	if err != nil {
+		return nil, err
+	}
+	return BuildHAStatus(s.brand, results)
+}
+
+/**
+ * 生成高可用采集的命令：带%s的命令（如华为的display dfs-group %s m-lag）按设备上配置的M-LAG组编号展开，未配置M-LAG时跳过
+ * @param	driver，设备的Driver，timeout，读取单条命令回显的超时时间（秒）
+ * @return  展开后的命令和查询M-LAG组的错误
+ */
+func (s *SSHSession) haStatusCmds(driver Driver, timeout int) ([]string, error) {
+	cmds := driver.GetterCmds(GetterHAStatus)
+	groupIDs := make([]string, 0)
+	if len(driver.GetterCmds(GetterMLAGGroups)) > 0 {
+		results, err := s.runGetter(GetterMLAGGroups, timeout)
+		if err != nil {
+			return nil, err
+		}
+		groups, _, err := ParseInto[mlagGroupRecord](results[MLAGGroupsTemplate])
+		if err != nil {
+			return nil, err
+		}
+		for _, group := range groups {
+			groupIDs = append(groupIDs, group.GroupID)
+		}
+	}
+	expanded := make([]string, 0, len(cmds))
+	for _, cmd := range cmds {
+		if !strings.Contains(cmd, "%s") {
+			expanded = append(expanded, cmd)
+			continue
+		}
+		for _, id := range groupIDs {
+			expanded = append(expanded, strings.ReplaceAll(cmd, "%s", id))
+		}
+	}
+	return expanded, nil
+}
+
+/**
+ * 将stack_members、mlag、vrrp模板的解析结果转换为高可用状态
+ * @param	brand，品牌名称，results，以模板名称为key的解析结果
+ * @return  高可用状态和转换错误
+ */
+func BuildHAStatus(brand string, results map[string][]map[string]interface{}) (*HAStatus, error) {
+	status := &HAStatus{}
+	members, _, err := ParseInto[stackMemberRecord](results[StackMembersTemplate])
+	if err != nil {
+		return nil, err
+	}
+	for _, member := range members {
+		mac := ""
+		if member.MAC != "" {
+			if mac, err = NormalizeMAC(member.MAC); err != nil {
+				return nil, err
+			}
+		}
+		status.StackMembers = append(status.StackMembers, StackMember{
+			ID:         member.MemberID,
+			Role:       strings.ToLower(member.Role),
+			Priority:   member.Priority,
+			MAC:        mac,
+			DeviceType: member.DeviceType,
+		})
+	}
+	mlags, _, err := ParseInto[mlagRecord](results[MLAGTemplate])
+	if err != nil {
+		return nil, err
+	}
+	// 多条命令的结果合并为一个状态，先出现的非空字段优先
+	for _, record := range mlags {
+		if status.MLAG == nil {
+			status.MLAG = &MLAGStatus{}
+		}
+		mergeMLAGRecord(status.MLAG, record)
+	}
+	groups, _, err := ParseInto[vrrpRecord](results[VRRPTemplate])
+	if err != nil {
+		return nil, err
+	}
+	for _, group := range groups {
+		status.VRRP = append(status.VRRP, VRRPGroup{
+			VRID:      group.VRID,
+			Interface: CanonicalPortName(brand, group.Interface),
+			State:     strings.ToLower(group.State),
+			VirtualIP: group.VirtualIP,
+			Priority:  group.Priority,
+		})
+	}
+	return status, nil
+}
+
+// 将一条mlag记录中的非空字段合并到状态中
+func mergeMLAGRecord(mlag *MLAGStatus, record mlagRecord) {
+	if mlag.GroupID == 0 {
+		mlag.GroupID = record.DfsGroupID
+	}
+	if mlag.LocalRole == "" {
+		mlag.LocalRole = strings.ToLower(record.LocalRole)
+	}
+	if mlag.Priority == 0 {
+		mlag.Priority = record.Priority
+	}
+	if mlag.PeerLink == "" {
+		mlag.PeerLink = record.PeerLink
+	}
+	if mlag.PeerLinkStatus == "" {
+		mlag.PeerLinkStatus = strings.ToLower(record.PeerLinkStatus)
+	}
+	if mlag.KeepaliveStatus == "" {
+		mlag.KeepaliveStatus = strings.ToLower(record.KeepaliveStatus)
+	}
+}
+
+/**
+ * 汇总高可用的异常项，变更窗口前检查使用：堆叠有多个成员但没有standby、M-LAG心跳或peer-link不正常、VRRP备份组未生效
+ * @return  异常项描述，如"mlag keepalive:lost"，没有异常时为空
+ */
+func (h *HAStatus) Warnings() []string {
+	warnings := make([]string, 0)
+	if len(h.StackMembers) > 1 {
+		hasStandby := false
+		for _, member := range h.StackMembers {
+			if member.Role == StackRoleStandby {
+				hasStandby = true
+			}
+			if member.Role != StackRoleMaster && member.Role != StackRoleStandby && member.Role != StackRoleSlave {
+				warnings = append(warnings, fmt.Sprintf("stack member %d:%s", member.ID, member.Role))
+			}
+		}
+		if !hasStandby {
+			warnings = append(warnings, "stack:no standby member")
+		}
+	}
+	if h.MLAG != nil {
+		if h.MLAG.KeepaliveStatus != "" && !normalLinkStates[h.MLAG.KeepaliveStatus] {
+			warnings = append(warnings, "mlag keepalive:"+h.MLAG.KeepaliveStatus)
+		}
+		if h.MLAG.PeerLinkStatus != "" && !normalLinkStates[h.MLAG.PeerLinkStatus] {
+			warnings = append(warnings, "mlag peer-link:"+h.MLAG.PeerLinkStatus)
+		}
+	}
+	for _, group := range h.VRRP {
+		if !normalVRRPStates[group.State] {
+			warnings = append(warnings, fmt.Sprintf("vrrp %s vrid %d:%s", group.Interface, group.VRID, group.State))
+		}
+	}
+	return warnings
+}
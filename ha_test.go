@@ -0,0 +1,149 @@
+package arkssh
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestBuildHAStatus(t *testing.T) {
+	status, err := BuildHAStatus(HUAWEI, map[string][]map[string]interface{}{
+		StackMembersTemplate: fixtureRecords(t, HUAWEI, StackMembersTemplate, "ce6881"),
+		MLAGTemplate:         fixtureRecords(t, HUAWEI, MLAGTemplate, "local_second"),
+		VRRPTemplate:         fixtureRecords(t, HUAWEI, VRRPTemplate, "vlanif"),
+	})
+	if err != nil {
+		t.Fatalf("BuildHAStatus err:%v", err)
+	}
+	wantMembers := []StackMember{
+		{ID: 1, Role: StackRoleMaster, Priority: 200, MAC: "0c:45:ba:12:34:00", DeviceType: "CE6881-48S6CQ"},
+		{ID: 2, Role: StackRoleStandby, Priority: 150, MAC: "0c:45:ba:12:35:00", DeviceType: "CE6881-48S6CQ"},
+		{ID: 3, Role: StackRoleSlave, Priority: 100, MAC: "0c:45:ba:12:36:00", DeviceType: "CE6881-48S6CQ"},
+	}
+	if !reflect.DeepEqual(status.StackMembers, wantMembers) {
+		t.Fatalf("stack members got %+v", status.StackMembers)
+	}
+	//本端为Node 2
+	wantMLAG := &MLAGStatus{GroupID: 1, LocalRole: "backup", Priority: 120, KeepaliveStatus: "lost"}
+	if !reflect.DeepEqual(status.MLAG, wantMLAG) {
+		t.Fatalf("mlag got %+v", status.MLAG)
+	}
+	if len(status.VRRP) != 3 || status.VRRP[1] != (VRRPGroup{VRID: 2, Interface: "Vlanif200", State: "backup", VirtualIP: "10.1.200.254"}) {
+		t.Fatalf("vrrp got %+v", status.VRRP)
+	}
+	if warnings := status.Warnings(); !reflect.DeepEqual(warnings, []string{"mlag keepalive:lost"}) {
+		t.Fatalf("warnings got %v", warnings)
+	}
+}
+
+func TestBuildHAStatusH3C(t *testing.T) {
+	//summary和role两条命令的结果合并为一个M-LAG状态
+	mlag := append(fixtureRecords(t, H3C, MLAGTemplate, "summary"), fixtureRecords(t, H3C, MLAGTemplate, "role")...)
+	status, err := BuildHAStatus(H3C, map[string][]map[string]interface{}{
+		StackMembersTemplate: fixtureRecords(t, H3C, StackMembersTemplate, "irf"),
+		MLAGTemplate:         mlag,
+		VRRPTemplate:         fixtureRecords(t, H3C, VRRPTemplate, "vlan"),
+	})
+	if err != nil {
+		t.Fatalf("BuildHAStatus err:%v", err)
+	}
+	if len(status.StackMembers) != 2 || status.StackMembers[0].ID != 1 || status.StackMembers[0].Role != StackRoleMaster || status.StackMembers[1].MAC != "00:e0:fc:0f:8c:03" {
+		t.Fatalf("stack members got %+v", status.StackMembers)
+	}
+	wantMLAG := &MLAGStatus{LocalRole: "primary", Priority: 123, PeerLink: "BAGG1000", PeerLinkStatus: "up", KeepaliveStatus: "up"}
+	if !reflect.DeepEqual(status.MLAG, wantMLAG) {
+		t.Fatalf("mlag got %+v", status.MLAG)
+	}
+	if len(status.VRRP) != 2 || status.VRRP[0].Interface != "Vlan-interface100" || status.VRRP[0].Priority != 120 || status.VRRP[0].State != "master" {
+		t.Fatalf("vrrp got %+v", status.VRRP)
+	}
+	if warnings := status.Warnings(); len(warnings) != 0 {
+		t.Fatalf("warnings got %v", warnings)
+	}
+
+	status, _ = BuildHAStatus(H3C, map[string][]map[string]interface{}{
+		StackMembersTemplate: {{"member_id": "1", "role": "Master", "priority": "32", "mac": "00e0-fc0f-8c02"}, {"member_id": "2", "role": "Loading", "priority": "1", "mac": "00e0-fc0f-8c03"}},
+		MLAGTemplate:         fixtureRecords(t, H3C, MLAGTemplate, "keepalive_down"),
+	})
+	want := []string{"stack member 2:loading", "stack:no standby member", "mlag keepalive:down", "mlag peer-link:down"}
+	if warnings := status.Warnings(); !reflect.DeepEqual(warnings, want) {
+		t.Fatalf("warnings got %v", warnings)
+	}
+}
+
+func TestHAStatusTemplateIndex(t *testing.T) {
+	cases := []struct {
+		brand, cmd, template string
+	}{
+		{HUAWEI, "display stack", StackMembersTemplate},
+		{HUAWEI, "display dfs-group 1 m-lag", MLAGTemplate},
+		{HUAWEI, "display m-lag peer-link", MLAGTemplate},
+		{HUAWEI, "display current-configuration configuration dfs-group", MLAGGroupsTemplate},
+		{HUAWEI, "display vrrp brief", VRRPTemplate},
+		{H3C, "display irf", StackMembersTemplate},
+		{H3C, "display m-lag summary", MLAGTemplate},
+		{H3C, "display m-lag role", MLAGTemplate},
+		{H3C, "display vrrp", VRRPTemplate},
+	}
+	for _, c := range cases {
+		if got, found := LookupTemplate(c.brand, c.cmd); !found || got != c.template {
+			t.Fatalf("%s %s got %s", c.brand, c.cmd, got)
+		}
+	}
+}
+
+// 读取用例的原始回显，去掉第一行的提示符和命令，作为脚本会话的回显
+func fixtureReply(t *testing.T, brand, templateName, fixture string) string {
+	raw, err := os.ReadFile(filepath.Join(fixtureRoot, brand, templateName, fixture+FixtureRawExt))
+	if err != nil {
+		t.Fatal(err)
+	}
+	text := string(raw)
+	return text[strings.Index(text, "\n")+1:]
+}
+
+func TestGetHAStatusDFSGroup(t *testing.T) {
+	groups := fixtureReply(t, HUAWEI, MLAGGroupsTemplate, "ce6881")
+	mlag := strings.ReplaceAll(fixtureReply(t, HUAWEI, MLAGTemplate, "ce6881"), "Dfs-Group ID   : 1", "Dfs-Group ID   : 2")
+	peerLink := fixtureReply(t, HUAWEI, MLAGTemplate, "peer_link")
+	reply := func(cmd string) string {
+		switch cmd {
+		case "display current-configuration configuration dfs-group":
+			return groups
+		case "display dfs-group 2 m-lag":
+			return mlag
+		case "display m-lag peer-link":
+			return peerLink
+		default:
+			return "\r\n<CE-01>"
+		}
+	}
+	//按设备上配置的DFS组编号查询，不固定为1
+	s, received := newScriptedSession(HUAWEI, reply)
+	status, err := s.GetHAStatus(1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []string{"display current-configuration configuration dfs-group", "display stack", "display dfs-group 2 m-lag",
+		"display m-lag peer-link", "display vrrp brief"}
+	if strings.Join(*received, "|") != strings.Join(want, "|") {
+		t.Fatalf("sent %q, want %q", *received, want)
+	}
+	wantMLAG := &MLAGStatus{GroupID: 2, LocalRole: "master", Priority: 150, PeerLink: "Eth-Trunk0", PeerLinkStatus: "down", KeepaliveStatus: "ok"}
+	if !reflect.DeepEqual(status.MLAG, wantMLAG) {
+		t.Fatalf("mlag got %+v", status.MLAG)
+	}
+	if warnings := status.Warnings(); !reflect.DeepEqual(warnings, []string{"mlag peer-link:down"}) {
+		t.Fatalf("warnings got %v", warnings)
+	}
+	//未配置DFS组时不查询dfs-group
+	s, received = newScriptedSession(HUAWEI, func(string) string { return "\r\n<CE-01>" })
+	if _, err := s.GetHAStatus(1); err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(strings.Join(*received, "|"), "display dfs-group") {
+		t.Fatalf("sent %q", *received)
+	}
+}
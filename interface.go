@@ -0,0 +1,420 @@
+package arkssh
+
+import (
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// 接口配置和接口状态对应的textfsm模板名称
+const (
+	IntfInfosTemplate   = "intf_infos"
+	IntfUpDownsTemplate = "intf_up_downs"
+)
+
+// vlan的取值范围
+const (
+	minVlanID = 1
+	maxVlanID = 4094
+)
+
+/**
+ * 规范化后的接口信息，由接口配置（intf_infos）和接口状态（intf_up_downs）合并得到
+ * @attr Name:规范化的端口名（缩写展开为全称，如XGE1/0/1 -> Ten-GigabitEthernet1/0/1），RawName:设备回显中的端口名，
+ *       Mode:access/trunk/hybrid，AccessVlan:access口的vlan，NativeVlan:trunk口的pvid，AllowedVlans:trunk允许通过的vlan（已去掉undo/remove的vlan），
+ *       IsLag:是否为聚合口，Lag:所属聚合口的名称，Members:聚合口的成员端口，AdminStatus:管理状态up/down，OperStatus:物理状态up/down，Protocol:协议状态
+ */
+type Interface struct {
+	Name         string   `bson:"name" json:"name"`
+	RawName      string   `bson:"raw_name,omitempty" json:"raw_name,omitempty"`
+	Description  string   `bson:"description,omitempty" json:"description,omitempty"`
+	Mode         string   `bson:"mode,omitempty" json:"mode,omitempty"`
+	AccessVlan   int      `bson:"access_vlan,omitempty" json:"access_vlan,omitempty"`
+	NativeVlan   int      `bson:"native_vlan,omitempty" json:"native_vlan,omitempty"`
+	AllowedVlans VlanSet  `bson:"allowed_vlans,omitempty" json:"allowed_vlans,omitempty"`
+	IsLag        bool     `bson:"is_lag,omitempty" json:"is_lag,omitempty"`
+	Lag          string   `bson:"lag,omitempty" json:"lag,omitempty"`
+	Members      []string `bson:"members,omitempty" json:"members,omitempty"`
+	AdminStatus  string   `bson:"admin_status,omitempty" json:"admin_status,omitempty"`
+	OperStatus   string   `bson:"oper_status,omitempty" json:"oper_status,omitempty"`
+	Protocol     string   `bson:"protocol,omitempty" json:"protocol,omitempty"`
+}
+
+// 已展开的vlan集合，升序且不重复；json中以1059,1063,2501-2502的形式表示
+type VlanSet []int
+
+var (
+	// 端口名拆分为类型和编号，如10GE1/0/1 -> 10GE、1/0/1，GigabitEthernet 0/1 -> GigabitEthernet、0/1
+	portNameRegexp = regexp.MustCompile(`^(\d*[A-Za-z][A-Za-z-]*?)\s*(\d.*)$`)
+	// 各品牌端口类型的缩写（小写）与全称，回显中的全称也需要列出以统一大小写
+	portAbbreviations = map[string]map[string]string{
+		HUAWEI: {
+			"ge": "GigabitEthernet", "gigabitethernet": "GigabitEthernet", "xge": "XGigabitEthernet", "xgigabitethernet": "XGigabitEthernet",
+			"10ge": "10GE", "25ge": "25GE", "40ge": "40GE", "100ge": "100GE", "400ge": "400GE",
+			"eth-trunk": "Eth-Trunk", "meth": "MEth", "vlanif": "Vlanif", "loopback": "LoopBack",
+		},
+		H3C: {
+			"ge": "GigabitEthernet", "gigabitethernet": "GigabitEthernet", "xge": "Ten-GigabitEthernet", "ten-gigabitethernet": "Ten-GigabitEthernet",
+			"wge": "TwentyFiveGigE", "twentyfivegige": "TwentyFiveGigE", "fge": "FortyGigE", "fortygige": "FortyGigE", "hge": "HundredGigE", "hundredgige": "HundredGigE",
+			"bagg": "Bridge-Aggregation", "bridge-aggregation": "Bridge-Aggregation", "ragg": "Route-Aggregation", "route-aggregation": "Route-Aggregation",
+			"mge": "M-GigabitEthernet", "m-gigabitethernet": "M-GigabitEthernet", "vlan-interface": "Vlan-interface", "vlan-int": "Vlan-interface", "vlan": "Vlan-interface",
+		},
+		CISCO: {
+			"fa": "FastEthernet", "fastethernet": "FastEthernet", "gi": "GigabitEthernet", "gig": "GigabitEthernet", "gigabitethernet": "GigabitEthernet",
+			"te": "TenGigabitEthernet", "ten": "TenGigabitEthernet", "tengigabitethernet": "TenGigabitEthernet", "twe": "TwentyFiveGigE", "twentyfivegige": "TwentyFiveGigE",
+			"fo": "FortyGigabitEthernet", "fortygigabitethernet": "FortyGigabitEthernet", "hu": "HundredGigE", "hundredgige": "HundredGigE",
+			"eth": "Ethernet", "ethernet": "Ethernet", "po": "Port-channel", "port-channel": "Port-channel", "vl": "Vlan", "vlan": "Vlan", "lo": "Loopback", "loopback": "Loopback",
+		},
+		ARISTA: {
+			"et": "Ethernet", "eth": "Ethernet", "ethernet": "Ethernet", "po": "Port-Channel", "port-channel": "Port-Channel", "ma": "Management", "management": "Management",
+		},
+		RUIJIE: {
+			"gi": "GigabitEthernet", "gigabitethernet": "GigabitEthernet", "te": "TenGigabitEthernet", "tengigabitethernet": "TenGigabitEthernet",
+			"ag": "AggregatePort", "aggregateport": "AggregatePort",
+		},
+	}
+	// 各品牌聚合口的类型名称，成员口只记录了聚合组编号，需要拼接出聚合口名称
+	lagPrefixes = map[string]string{
+		HUAWEI:  "Eth-Trunk",
+		H3C:     "Bridge-Aggregation",
+		CISCO:   "Port-channel",
+		ARISTA:  "Port-Channel",
+		RUIJIE:  "AggregatePort",
+		ZTE:     "smartgroup",
+		DIPU:    "eth-trunk",
+		JUNIPER: "ae",
+	}
+)
+
+/**
+ * 外部调用的统一方法，获取设备所有物理口和聚合口的配置与状态
+ * @return 规范化后的接口列表和执行错误
+ */
+func (d *Device) GetInterfaces() ([]Interface, error) {
+	var interfaces []Interface
+	err := d.withSession(func(s *SSHSession) error {
+		var err error
+		interfaces, err = s.GetInterfaces(d.getterTimeout())
+		return err
+	})
+	if err != nil {
+		LogError("获取接口信息错误:%s,IP:%s", err.Error(), d.IP)
+		return nil, err
+	}
+	LogDebug("获取接口信息成功,IP:%s,接口数量:%d", d.IP, len(interfaces))
+	return interfaces, nil
+}
+
+/**
+ * 获取当前SSH到的设备的接口信息，执行的命令由Driver的GetterInterfaces定义
+ * @param	timeout，读取单条命令回显的超时时间（秒）
+ * @return 规范化后的接口列表和执行错误
+ */
+func (s *SSHSession) GetInterfaces(timeout int) ([]Interface, error) {
+	results, err := s.runGetter(GetterInterfaces, timeout)
+	if err != nil {
+		return nil, err
+	}
+	return BuildInterfaces(s.brand, results[IntfInfosTemplate], results[IntfUpDownsTemplate])
+}
+
+// intf_infos模板的一条记录
+type intfConfigRecord struct {
+	PortName    string   `textfsm:"port_name"`
+	AggPortID   int      `textfsm:"agg_port_id"`
+	Description string   `textfsm:"description"`
+	BeLongToAgg int      `textfsm:"be_long_to_agg"`
+	PortType    string   `textfsm:"port_type"`
+	AccessVlan  int      `textfsm:"access_vlan"`
+	Pvid        int      `textfsm:"pvid"`
+	NotAllow    []string `textfsm:"not_allow"`
+	AllowTmp    []string `textfsm:"allow_tmp"`
+}
+
+// intf_up_downs模板的一条记录
+type intfStatusRecord struct {
+	PortName       string `textfsm:"port_name"`
+	PhysicalStatus string `textfsm:"physical_status"`
+	ProtocolStatus string `textfsm:"protocol_status"`
+	AdminStatus    string `textfsm:"admin_status"`
+	AdminDown      string `textfsm:"admin_down"`
+	Description    string `textfsm:"description"`
+}
+
+/**
+ * 将接口配置和接口状态的解析结果合并为规范化的接口列表，同一端口出现在多条记录中时（如中兴的配置分散在多个配置段）合并为一个
+ * @param	brand，品牌名称，configRecords，intf_infos模板的解析结果，statusRecords，intf_up_downs模板的解析结果
+ * @return  接口列表（按配置中出现的顺序，只在状态中出现的端口排在后面）和转换错误
+ */
+func BuildInterfaces(brand string, configRecords, statusRecords []map[string]interface{}) ([]Interface, error) {
+	configs, _, err := ParseInto[intfConfigRecord](configRecords)
+	if err != nil {
+		return nil, err
+	}
+	statuses, _, err := ParseInto[intfStatusRecord](statusRecords)
+	if err != nil {
+		return nil, err
+	}
+	interfaces := make([]Interface, 0, len(configs))
+	indexes := make(map[string]int)
+	lookup := func(rawName string) *Interface {
+		name := CanonicalPortName(brand, rawName)
+		i, ok := indexes[name]
+		if !ok {
+			i = len(interfaces)
+			indexes[name] = i
+			interfaces = append(interfaces, Interface{Name: name, RawName: rawName, IsLag: isLagName(brand, name)})
+		}
+		return &interfaces[i]
+	}
+	for _, config := range configs {
+		intf := lookup(config.PortName)
+		if config.Description != "" {
+			intf.Description = config.Description
+		}
+		if config.PortType != "" {
+			intf.Mode = strings.ToLower(config.PortType)
+		}
+		if config.AccessVlan != 0 {
+			intf.AccessVlan = config.AccessVlan
+		}
+		if config.Pvid != 0 {
+			intf.NativeVlan = config.Pvid
+		}
+		if config.AggPortID != 0 {
+			intf.IsLag = true
+		}
+		if config.BeLongToAgg != 0 && lagPrefixes[brand] != "" {
+			intf.Lag = CanonicalPortName(brand, lagPrefixes[brand]+strconv.Itoa(config.BeLongToAgg))
+		}
+		if len(config.AllowTmp) > 0 {
+			allowed, err := ParseVlanList(config.AllowTmp...)
+			if err != nil {
+				return nil, fmt.Errorf("端口%s允许的vlan解析失败:%v", config.PortName, err)
+			}
+			intf.AllowedVlans = intf.AllowedVlans.Union(allowed)
+		}
+		if len(config.NotAllow) > 0 {
+			removed, err := ParseVlanList(config.NotAllow...)
+			if err != nil {
+				return nil, fmt.Errorf("端口%s移除的vlan解析失败:%v", config.PortName, err)
+			}
+			intf.AllowedVlans = intf.AllowedVlans.Remove(removed...)
+		}
+	}
+	for _, status := range statuses {
+		intf := lookup(status.PortName)
+		intf.AdminStatus, intf.OperStatus = normalizeLinkStatus(status.PhysicalStatus)
+		if status.AdminStatus != "" {
+			intf.AdminStatus = strings.ToLower(status.AdminStatus)
+		}
+		if status.AdminDown != "" {
+			intf.AdminStatus = "down"
+		}
+		intf.Protocol = strings.ToLower(status.ProtocolStatus)
+		if intf.Description == "" {
+			intf.Description = status.Description
+		}
+	}
+	//成员口记录了所属的聚合口，反向填充聚合口的成员
+	for i := range interfaces {
+		if interfaces[i].Lag == "" {
+			continue
+		}
+		member := interfaces[i].Name
+		lag := lookup(interfaces[i].Lag)
+		lag.IsLag = true
+		lag.Members = append(lag.Members, member)
+	}
+	return interfaces, nil
+}
+
+/**
+ * 将端口名规范化：缩写展开为全称并统一大小写，去掉类型与编号之间的空格，如XGE1/0/1 -> Ten-GigabitEthernet1/0/1（华三）、Gi1/0/1 -> GigabitEthernet1/0/1（思科）
+ * @param	brand，品牌名称，name，端口名
+ * @return  规范化后的端口名，无法识别的端口名只去掉空格
+ */
+func CanonicalPortName(brand, name string) string {
+	name = strings.TrimSpace(name)
+	match := portNameRegexp.FindStringSubmatch(name)
+	if match == nil {
+		return name
+	}
+	prefix, number := match[1], match[2]
+	if full, ok := portAbbreviations[brand][strings.ToLower(prefix)]; ok {
+		prefix = full
+	}
+	return prefix + number
+}
+
+// 判断端口名是否为聚合口
+func isLagName(brand, name string) bool {
+	prefix := lagPrefixes[brand]
+	if prefix == "" {
+		return false
+	}
+	match := portNameRegexp.FindStringSubmatch(name)
+	return match != nil && strings.EqualFold(match[1], prefix)
+}
+
+// 将设备回显中的端口状态转换为管理状态和物理状态，如华为的*down和ADM、思科的administratively down表示管理员关闭
+func normalizeLinkStatus(status string) (admin, oper string) {
+	status = strings.ToLower(strings.TrimSpace(status))
+	switch {
+	case status == "":
+		return "", ""
+	case strings.HasPrefix(status, "adm") || strings.HasPrefix(status, "admin") || status == "*down" || status == "disabled":
+		return "down", "down"
+	case status == "up" || status == "monitoring":
+		return "up", "up"
+	default:
+		return "up", "down"
+	}
+}
+
+/**
+ * 将设备配置中的vlan列表展开为集合，支持华为/华三的1059 1063 2501 to 2502、思科/中兴的1059,1063,2501-2502以及all、none
+ * @param	texts，vlan列表，多行配置（如思科的allowed vlan add）依次传入
+ * @return  vlan集合和格式错误
+ */
+func ParseVlanList(texts ...string) (VlanSet, error) {
+	ids := make([]int, 0)
+	for _, text := range texts {
+		tokens := strings.Fields(strings.ReplaceAll(strings.ToLower(text), ",", " "))
+		for i := 0; i < len(tokens); i++ {
+			token := tokens[i]
+			switch {
+			case token == "all":
+				ids = appendVlanRange(ids, minVlanID, maxVlanID)
+			case token == "none":
+			case i+2 < len(tokens) && tokens[i+1] == "to":
+				start, end, err := parseVlanRange(token, tokens[i+2])
+				if err != nil {
+					return nil, err
+				}
+				ids = appendVlanRange(ids, start, end)
+				i += 2
+			case strings.Contains(token, "-"):
+				bounds := strings.SplitN(token, "-", 2)
+				start, end, err := parseVlanRange(bounds[0], bounds[1])
+				if err != nil {
+					return nil, err
+				}
+				ids = appendVlanRange(ids, start, end)
+			default:
+				id, err := parseVlanID(token)
+				if err != nil {
+					return nil, err
+				}
+				ids = append(ids, id)
+			}
+		}
+	}
+	return newVlanSet(ids), nil
+}
+
+func parseVlanRange(startText, endText string) (int, int, error) {
+	start, err := parseVlanID(startText)
+	if err != nil {
+		return 0, 0, err
+	}
+	end, err := parseVlanID(endText)
+	if err != nil {
+		return 0, 0, err
+	}
+	if start > end {
+		return 0, 0, fmt.Errorf("vlan范围错误:%d-%d", start, end)
+	}
+	return start, end, nil
+}
+
+func parseVlanID(text string) (int, error) {
+	id, err := strconv.Atoi(text)
+	if err != nil || id < minVlanID || id > maxVlanID {
+		return 0, fmt.Errorf("无法识别的vlan:%s", text)
+	}
+	return id, nil
+}
+
+func appendVlanRange(ids []int, start, end int) []int {
+	for id := start; id <= end; id++ {
+		ids = append(ids, id)
+	}
+	return ids
+}
+
+// 排序并去重
+func newVlanSet(ids []int) VlanSet {
+	sort.Ints(ids)
+	set := make(VlanSet, 0, len(ids))
+	for i, id := range ids {
+		if i == 0 || id != ids[i-1] {
+			set = append(set, id)
+		}
+	}
+	return set
+}
+
+// Contains 判断vlan是否在集合中
+func (v VlanSet) Contains(id int) bool {
+	i := sort.SearchInts(v, id)
+	return i < len(v) && v[i] == id
+}
+
+// Union 返回两个集合的并集
+func (v VlanSet) Union(other VlanSet) VlanSet {
+	ids := make([]int, 0, len(v)+len(other))
+	ids = append(ids, v...)
+	ids = append(ids, other...)
+	return newVlanSet(ids)
+}
+
+// Remove 返回去掉指定vlan后的集合
+func (v VlanSet) Remove(ids ...int) VlanSet {
+	removed := newVlanSet(append([]int(nil), ids...))
+	set := make(VlanSet, 0, len(v))
+	for _, id := range v {
+		if !removed.Contains(id) {
+			set = append(set, id)
+		}
+	}
+	return set
+}
+
+// String 以1059,1063,2501-2502的形式输出
+func (v VlanSet) String() string {
+	parts := make([]string, 0)
+	for i := 0; i < len(v); {
+		j := i
+		for j+1 < len(v) && v[j+1] == v[j]+1 {
+			j++
+		}
+		if i == j {
+			parts = append(parts, strconv.Itoa(v[i]))
+		} else {
+			parts = append(parts, strconv.Itoa(v[i])+"-"+strconv.Itoa(v[j]))
+		}
+		i = j + 1
+	}
+	return strings.Join(parts, ",")
+}
+
+func (v VlanSet) MarshalJSON() ([]byte, error) {
+	return json.Marshal(v.String())
+}
+
+func (v *VlanSet) UnmarshalJSON(data []byte) error {
+	var text string
+	if err := json.Unmarshal(data, &text); err != nil {
+		return err
+	}
+	set, err := ParseVlanList(text)
+	if err != nil {
+		return err
+	}
+	*v = set
+	return nil
+}
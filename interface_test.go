@@ -0,0 +1,111 @@
+package arkssh
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestParseVlanList(t *testing.T) {
+	cases := []struct {
+		texts []string
+		want  string
+	}{
+		{[]string{"1059 1063 2501 to 2502"}, "1059,1063,2501-2502"},
+		{[]string{"1059,1063,2501-2502", "3001"}, "1059,1063,2501-2502,3001"},
+		{[]string{"10 to 12 11"}, "10-12"},
+		{[]string{"none"}, ""},
+		{[]string{"all"}, "1-4094"},
+	}
+	for _, c := range cases {
+		got, err := ParseVlanList(c.texts...)
+		if err != nil || got.String() != c.want {
+			t.Errorf("ParseVlanList(%q)=%q,%v, want %q", c.texts, got, err, c.want)
+		}
+	}
+	for _, text := range []string{"0", "4095", "abc", "20 to 10"} {
+		if _, err := ParseVlanList(text); err == nil {
+			t.Errorf("ParseVlanList(%q) should fail", text)
+		}
+	}
+	set, _ := ParseVlanList("1-3 5")
+	if !set.Contains(2) || set.Contains(4) || set.Remove(2).String() != "1,3,5" {
+		t.Fatalf("VlanSet operations wrong: %v", set)
+	}
+	data, _ := json.Marshal(set)
+	var decoded VlanSet
+	if err := json.Unmarshal(data, &decoded); err != nil || !reflect.DeepEqual(decoded, set) {
+		t.Fatalf("VlanSet json round trip got %v from %s", decoded, data)
+	}
+}
+
+func TestCanonicalPortName(t *testing.T) {
+	cases := []struct{ brand, name, want string }{
+		{H3C, "XGE1/0/1", "Ten-GigabitEthernet1/0/1"},
+		{H3C, "BAGG1", "Bridge-Aggregation1"},
+		{HUAWEI, "10GE1/0/1", "10GE1/0/1"},
+		{HUAWEI, "XGE0/0/1", "XGigabitEthernet0/0/1"},
+		{CISCO, "Gi1/0/1", "GigabitEthernet1/0/1"},
+		{CISCO, "port-channel10", "Port-channel10"},
+		{RUIJIE, "GigabitEthernet 0/1", "GigabitEthernet0/1"},
+		{ZTE, "gei-0/1/0/1", "gei-0/1/0/1"},
+	}
+	for _, c := range cases {
+		if got := CanonicalPortName(c.brand, c.name); got != c.want {
+			t.Errorf("CanonicalPortName(%s, %q)=%q, want %q", c.brand, c.name, got, c.want)
+		}
+	}
+}
+
+// 使用textfsm测试用例中的回显构造接口列表
+func fixtureRecords(t *testing.T, brand, templateName, fixture string) []map[string]interface{} {
+	raw, err := os.ReadFile(filepath.Join(fixtureRoot, brand, templateName, fixture+FixtureRawExt))
+	if err != nil {
+		t.Fatal(err)
+	}
+	records, err := parseTemplateRecords(brand, string(raw), templateName)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return records
+}
+
+func TestBuildInterfaces(t *testing.T) {
+	vlans, _ := ParseVlanList("1059 1063 2501 to 2502")
+	want := []Interface{
+		{Name: "Bridge-Aggregation1", RawName: "Bridge-Aggregation1", Description: "to-server-01", Mode: "trunk", AllowedVlans: vlans,
+			IsLag: true, Members: []string{"Ten-GigabitEthernet1/0/1"}, AdminStatus: "up", OperStatus: "up", Protocol: "up"},
+		{Name: "Ten-GigabitEthernet1/0/1", RawName: "Ten-GigabitEthernet1/0/1", Description: "server-01-eth0", Lag: "Bridge-Aggregation1",
+			AdminStatus: "up", OperStatus: "up", Protocol: "up"},
+		{Name: "Ten-GigabitEthernet1/0/2", RawName: "Ten-GigabitEthernet1/0/2", Mode: "access", AccessVlan: 100,
+			AdminStatus: "down", OperStatus: "down", Protocol: "down"},
+	}
+	got, err := BuildInterfaces(H3C, fixtureRecords(t, H3C, IntfInfosTemplate, "current"), fixtureRecords(t, H3C, IntfUpDownsTemplate, "interface"))
+	if err != nil {
+		t.Fatalf("BuildInterfaces err:%v", err)
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("BuildInterfaces got\n%+v\nwant\n%+v", got, want)
+	}
+
+	//思科的allowed vlan remove可以是列表或范围，并且可以有多行
+	got, err = BuildInterfaces(CISCO, fixtureRecords(t, CISCO, IntfInfosTemplate, "remove"), nil)
+	if err != nil {
+		t.Fatalf("BuildInterfaces err:%v", err)
+	}
+	if len(got) != 2 || got[0].AllowedVlans.String() != "1-9,11-19,21-29,41-100" || got[1].AllowedVlans.String() != "100" {
+		t.Fatalf("BuildInterfaces(cisco remove) got %+v", got)
+	}
+
+	//中兴同一端口的配置分散在多个配置段中
+	got, err = BuildInterfaces(ZTE, fixtureRecords(t, ZTE, IntfInfosTemplate, "v5"), fixtureRecords(t, ZTE, IntfUpDownsTemplate, "brief"))
+	if err != nil {
+		t.Fatalf("BuildInterfaces err:%v", err)
+	}
+	if len(got) != 4 || got[0].Name != "smartgroup1" || got[0].Mode != "trunk" || got[0].NativeVlan != 10 ||
+		!reflect.DeepEqual(got[0].Members, []string{"gei-0/1/0/1"}) || got[2].AccessVlan != 100 || got[3].OperStatus != "down" {
+		t.Fatalf("BuildInterfaces(zte) got %+v", got)
+	}
+}
@@ -0,0 +1,183 @@
+package arkssh
+
+import (
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+)
+
+// MAC地址表对应的textfsm模板名称
+const MACTablesTemplate = "mac_tables"
+
+// MAC表项的类型
+const (
+	MACTypeDynamic   = "dynamic"
+	MACTypeStatic    = "static"
+	MACTypeSecure    = "secure"
+	MACTypeBlackhole = "blackhole"
+)
+
+/**
+ * 规范化后的MAC表项
+ * @attr MAC:小写冒号分隔的MAC地址（如00:50:56:94:1a:2b），Vlan:所属vlan，Port:规范化的端口名，Type:表项类型（dynamic/static/secure/blackhole），设备未显示时为空
+ */
+type MACEntry struct {
+	MAC  string `bson:"mac" json:"mac"`
+	Vlan int    `bson:"vlan,omitempty" json:"vlan,omitempty"`
+	Port string `bson:"port,omitempty" json:"port,omitempty"`
+	Type string `bson:"type,omitempty" json:"type,omitempty"`
+}
+
+/**
+ * MAC地址在某台设备上的位置
+ * @attr IP:设备IP，Brand:设备品牌，Entry:对应的MAC表项
+ */
+type MACLocation struct {
+	IP    string   `bson:"ip" json:"ip"`
+	Brand string   `bson:"brand,omitempty" json:"brand,omitempty"`
+	Entry MACEntry `bson:"entry" json:"entry"`
+}
+
+// mac_tables模板的一条记录
+type macTableRecord struct {
+	MACAddress string `textfsm:"mac_address"`
+	Vlan       int    `textfsm:"vlan"`
+	PortName   string `textfsm:"port_name"`
+	Type       string `textfsm:"type"`
+}
+
+/**
+ * 外部调用的统一方法，获取设备的MAC地址表
+ * @return 规范化后的MAC表项和执行错误
+ */
+func (d *Device) GetMACTable() ([]MACEntry, error) {
+	var entries []MACEntry
+	err := d.withSession(func(s *SSHSession) error {
+		var err error
+		entries, err = s.GetMACTable(d.getterTimeout())
+		return err
+	})
+	if err != nil {
+		LogError("获取MAC地址表错误:%s,IP:%s", err.Error(), d.IP)
+		return nil, err
+	}
+	LogDebug("获取MAC地址表成功,IP:%s,表项数量:%d", d.IP, len(entries))
+	return entries, nil
+}
+
+/**
+ * 获取当前SSH到的设备的MAC地址表，执行的命令由Driver的GetterMACTable定义
+ * @param	timeout，读取单条命令回显的超时时间（秒）
+ * @return 规范化后的MAC表项和执行错误
+ */
+func (s *SSHSession) GetMACTable(timeout int) ([]MACEntry, error) {
+	results, err := s.runGetter(GetterMACTable, timeout)
+	if err != nil {
+		return nil, err
+	}
+	return BuildMACTable(s.brand, results[MACTablesTemplate])
+}
+
+/**
+ * 将mac_tables模板的解析结果转换为规范化的MAC表项
+ * @param	brand，品牌名称，records，mac_tables模板的解析结果
+ * @return  MAC表项和转换错误
+ */
+func BuildMACTable(brand string, records []map[string]interface{}) ([]MACEntry, error) {
+	rows, _, err := ParseInto[macTableRecord](records)
+	if err != nil {
+		return nil, err
+	}
+	entries := make([]MACEntry, 0, len(rows))
+	for _, row := range rows {
+		mac, err := NormalizeMAC(row.MACAddress)
+		if err != nil {
+			return nil, err
+		}
+		entries = append(entries, MACEntry{
+			MAC:  mac,
+			Vlan: row.Vlan,
+			Port: CanonicalPortName(brand, row.PortName),
+			Type: normalizeMACType(row.Type),
+		})
+	}
+	return entries, nil
+}
+
+/**
+ * 将各厂商格式的MAC地址（0050-5694-1a2b、0050.5694.1a2b、00:50:56:94:1A:2B等）统一为小写冒号分隔的格式
+ * @param	mac，MAC地址
+ * @return  规范化后的MAC地址和格式错误
+ */
+func NormalizeMAC(mac string) (string, error) {
+	digits := strings.NewReplacer("-", "", ".", "", ":", "").Replace(strings.ToLower(strings.TrimSpace(mac)))
+	if len(digits) != 12 {
+		return "", fmt.Errorf("无法识别的MAC地址:%s", mac)
+	}
+	if _, err := hex.DecodeString(digits); err != nil {
+		return "", fmt.Errorf("无法识别的MAC地址:%s", mac)
+	}
+	parts := make([]string, 0, 6)
+	for i := 0; i < len(digits); i += 2 {
+		parts = append(parts, digits[i:i+2])
+	}
+	return strings.Join(parts, ":"), nil
+}
+
+// 统一表项类型，如华三的Learned为动态学习，Config static为静态配置，华为的security/sticky为端口安全
+func normalizeMACType(raw string) string {
+	lower := strings.ToLower(strings.TrimSpace(raw))
+	switch {
+	case lower == "":
+		return ""
+	case lower == "learned" || strings.Contains(lower, "dynamic"):
+		return MACTypeDynamic
+	case strings.Contains(lower, "static"):
+		return MACTypeStatic
+	case strings.HasPrefix(lower, "secur") || lower == "sticky":
+		return MACTypeSecure
+	case lower == "blackhole":
+		return MACTypeBlackhole
+	default:
+		return lower
+	}
+}
+
+/**
+ * 在一批设备上并发查找MAC地址所在的端口，并发方式与BulkRunCmd相同（每台设备一个协程）
+ * @param	devices，要查找的设备，mac，任意格式的MAC地址
+ * @return  按设备顺序排列的查找结果；部分设备采集失败时返回汇总的错误，成功设备的结果仍然返回
+ */
+func FindMAC(devices []Device, mac string) ([]MACLocation, error) {
+	target, err := NormalizeMAC(mac)
+	if err != nil {
+		return nil, err
+	}
+	found := make([][]MACLocation, len(devices))
+	errs := make([]error, len(devices))
+	wg := sync.WaitGroup{}
+	wg.Add(len(devices))
+	for i := range devices {
+		go func(i int, d *Device) {
+			defer wg.Done()
+			entries, err := d.GetMACTable()
+			if err != nil {
+				errs[i] = fmt.Errorf("IP:%s,err:%v", d.IP, err)
+				return
+			}
+			for _, entry := range entries {
+				if entry.MAC == target {
+					found[i] = append(found[i], MACLocation{IP: d.IP, Brand: d.Brand, Entry: entry})
+				}
+			}
+		}(i, &devices[i])
+	}
+	wg.Wait()
+	locations := make([]MACLocation, 0)
+	for _, items := range found {
+		locations = append(locations, items...)
+	}
+	return locations, errors.Join(errs...)
+}
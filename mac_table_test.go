@@ -0,0 +1,70 @@
+package arkssh
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestNormalizeMAC(t *testing.T) {
+	for _, mac := range []string{"0050-5694-1A2B", "0050.5694.1a2b", "00:50:56:94:1a:2b", "005056941a2b"} {
+		if got, err := NormalizeMAC(mac); err != nil || got != "00:50:56:94:1a:2b" {
+			t.Errorf("NormalizeMAC(%q)=%q,%v", mac, got, err)
+		}
+	}
+	for _, mac := range []string{"0050-5694-1a2", "0050-5694-1a2g", ""} {
+		if _, err := NormalizeMAC(mac); err == nil {
+			t.Errorf("NormalizeMAC(%q) should fail", mac)
+		}
+	}
+}
+
+func TestBuildMACTable(t *testing.T) {
+	got, err := BuildMACTable(H3C, fixtureRecords(t, H3C, MACTablesTemplate, "s6850"))
+	if err != nil {
+		t.Fatalf("BuildMACTable err:%v", err)
+	}
+	want := []MACEntry{
+		{MAC: "00:50:56:94:1a:2b", Vlan: 100, Port: "Ten-GigabitEthernet1/0/1", Type: MACTypeDynamic},
+		{MAC: "00:50:56:94:3c:4d", Vlan: 200, Port: "Bridge-Aggregation1", Type: MACTypeDynamic},
+		{MAC: "00:50:56:94:5e:6f", Vlan: 1, Port: "Ten-GigabitEthernet1/0/3", Type: MACTypeStatic},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("BuildMACTable got %+v, want %+v", got, want)
+	}
+	got, err = BuildMACTable(CISCO, fixtureRecords(t, CISCO, MACTablesTemplate, "nxos"))
+	if err != nil || len(got) != 2 || got[1].Port != "Port-channel10" || got[1].MAC != "00:50:56:94:3c:4d" {
+		t.Fatalf("BuildMACTable(cisco) got %+v,%v", got, err)
+	}
+}
+
+func TestFindMACInvalid(t *testing.T) {
+	if _, err := FindMAC(nil, "not-a-mac"); err == nil {
+		t.Fatalf("FindMAC should reject invalid mac")
+	}
+	locations, err := FindMAC(nil, "0050.5694.1a2b")
+	if err != nil || len(locations) != 0 {
+		t.Fatalf("FindMAC without devices got %v,%v", locations, err)
+	}
+}
+
+func TestFindMACBrandFromSession(t *testing.T) {
+	fixture := loadMacTableFixture(t)
+	body := fixture.Raw[strings.Index(fixture.Raw, "\n")+1:]
+	s, _ := newScriptedSession(HUAWEI, func(cmd string) string {
+		if cmd == "display mac-address" {
+			return body
+		}
+		return "\r\n<CE-01>"
+	})
+	//未指定品牌时结果中使用登录时识别到的品牌
+	devices := []Device{{IP: "192.0.2.11", Username: "admin", Password: "pass", Timeout: 1}}
+	cacheScriptedSession(t, &devices[0], s)
+	locations, err := FindMAC(devices, "0050.5694.1a2b")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(locations) != 1 || locations[0].Brand != HUAWEI || locations[0].IP != "192.0.2.11" {
+		t.Fatalf("FindMAC got %+v", locations)
+	}
+}
@@ -0,0 +1,123 @@
+package arkssh
+
+import (
+	"regexp"
+	"strings"
+)
+
+// LLDP、CDP邻居对应的textfsm模板名称
+const (
+	LLDPNeighborsTemplate = "lldp_neighbors"
+	CDPNeighborsTemplate  = "cdp_neighbors"
+)
+
+// 邻居的发现协议
+const (
+	NeighborProtocolLLDP = "lldp"
+	NeighborProtocolCDP  = "cdp"
+)
+
+// CDP的Device ID中附带的序列号，如N9K-01(FDO21120ABC)
+var cdpSerialSuffix = regexp.MustCompile(`\([^)]*\)$`)
+
+/**
+ * LLDP/CDP邻居
+ * @attr LocalPort:规范化的本端端口，RemoteSystem:对端系统名称，RemotePort:对端端口（对端设备显示的原始名称），
+ *       MgmtIP:对端管理地址，ChassisID:对端机框MAC（小写冒号分隔，非MAC格式时保持原样），Protocol:发现协议（lldp/cdp）
+ */
+type Neighbor struct {
+	LocalPort    string `bson:"local_port" json:"local_port"`
+	RemoteSystem string `bson:"remote_system,omitempty" json:"remote_system,omitempty"`
+	RemotePort   string `bson:"remote_port,omitempty" json:"remote_port,omitempty"`
+	MgmtIP       string `bson:"mgmt_ip,omitempty" json:"mgmt_ip,omitempty"`
+	ChassisID    string `bson:"chassis_id,omitempty" json:"chassis_id,omitempty"`
+	Protocol     string `bson:"protocol" json:"protocol"`
+}
+
+// lldp_neighbors、cdp_neighbors模板的一条记录
+type neighborRecord struct {
+	LocalPort    string `textfsm:"local_port"`
+	ChassisID    string `textfsm:"chassis_id"`
+	RemotePort   string `textfsm:"remote_port"`
+	RemoteSystem string `textfsm:"remote_system"`
+	MgmtIP       string `textfsm:"mgmt_ip"`
+}
+
+/**
+ * 外部调用的统一方法，获取设备的LLDP/CDP邻居
+ * @return 邻居和执行错误
+ */
+func (d *Device) GetNeighbors() ([]Neighbor, error) {
+	var neighbors []Neighbor
+	err := d.withSession(func(s *SSHSession) error {
+		var err error
+		neighbors, err = s.GetNeighbors(d.getterTimeout())
+		return err
+	})
+	if err != nil {
+		LogError("获取邻居错误:%s,IP:%s", err.Error(), d.IP)
+		return nil, err
+	}
+	LogDebug("获取邻居成功,IP:%s,邻居数量:%d", d.IP, len(neighbors))
+	return neighbors, nil
+}
+
+/**
+ * 获取当前SSH到的设备的LLDP/CDP邻居，执行的命令由Driver的GetterNeighbors定义
+ * @param	timeout，读取单条命令回显的超时时间（秒）
+ * @return 邻居和执行错误
+ */
+func (s *SSHSession) GetNeighbors(timeout int) ([]Neighbor, error) {
+	results, err := s.runGetter(GetterNeighbors, timeout)
+	if err != nil {
+		return nil, err
+	}
+	return BuildNeighbors(s.brand, results)
+}
+
+/**
+ * 将lldp_neighbors、cdp_neighbors模板的解析结果转换为邻居，同一端口上LLDP和CDP发现的同一邻居只保留LLDP的结果
+ * @param	brand，品牌名称，results，以模板名称为key的解析结果
+ * @return  邻居和转换错误
+ */
+func BuildNeighbors(brand string, results map[string][]map[string]interface{}) ([]Neighbor, error) {
+	neighbors := make([]Neighbor, 0)
+	seen := make(map[string]bool)
+	for _, item := range []struct{ template, protocol string }{
+		{LLDPNeighborsTemplate, NeighborProtocolLLDP},
+		{CDPNeighborsTemplate, NeighborProtocolCDP},
+	} {
+		rows, _, err := ParseInto[neighborRecord](results[item.template])
+		if err != nil {
+			return nil, err
+		}
+		for _, row := range rows {
+			neighbor := Neighbor{
+				LocalPort:    CanonicalPortName(brand, row.LocalPort),
+				RemoteSystem: cdpSerialSuffix.ReplaceAllString(row.RemoteSystem, ""),
+				RemotePort:   row.RemotePort,
+				MgmtIP:       row.MgmtIP,
+				ChassisID:    row.ChassisID,
+				Protocol:     item.protocol,
+			}
+			if mac, err := NormalizeMAC(row.ChassisID); err == nil {
+				neighbor.ChassisID = mac
+			}
+			key := neighbor.LocalPort + "|" + strings.ToLower(neighborSystemKey(neighbor.RemoteSystem))
+			if seen[key] {
+				continue
+			}
+			seen[key] = true
+			neighbors = append(neighbors, neighbor)
+		}
+	}
+	return neighbors, nil
+}
+
+// CDP的Device ID可能带有域名，比较时只使用第一段主机名
+func neighborSystemKey(system string) string {
+	if i := strings.Index(system, "."); i > 0 {
+		return system[:i]
+	}
+	return system
+}
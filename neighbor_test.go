@@ -0,0 +1,46 @@
+package arkssh
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestBuildNeighbors(t *testing.T) {
+	neighbors, err := BuildNeighbors(HUAWEI, map[string][]map[string]interface{}{
+		LLDPNeighborsTemplate: fixtureRecords(t, HUAWEI, LLDPNeighborsTemplate, "detail"),
+	})
+	if err != nil {
+		t.Fatalf("BuildNeighbors err:%v", err)
+	}
+	want := Neighbor{LocalPort: "GigabitEthernet1/0/1", RemoteSystem: "CE-02", RemotePort: "GE1/0/2", MgmtIP: "10.1.1.2", ChassisID: "0c:45:ba:12:35:00", Protocol: NeighborProtocolLLDP}
+	if len(neighbors) != 3 || neighbors[0] != want {
+		t.Fatalf("got %+v", neighbors)
+	}
+	//同一端口上的多个邻居
+	if neighbors[1].LocalPort != "GigabitEthernet1/0/48" || neighbors[2].LocalPort != "GigabitEthernet1/0/48" || neighbors[2].MgmtIP != "10.2.0.12" {
+		t.Fatalf("got %+v", neighbors[1:])
+	}
+}
+
+func TestBuildNeighborsLLDPAndCDP(t *testing.T) {
+	neighbors, err := BuildNeighbors(CISCO, map[string][]map[string]interface{}{
+		LLDPNeighborsTemplate: fixtureRecords(t, CISCO, LLDPNeighborsTemplate, "detail"),
+		CDPNeighborsTemplate:  fixtureRecords(t, CISCO, CDPNeighborsTemplate, "detail"),
+	})
+	if err != nil {
+		t.Fatalf("BuildNeighbors err:%v", err)
+	}
+	//Gi1/0/1上LLDP和CDP发现的是同一个邻居，只保留LLDP
+	var got [][]string
+	for _, n := range neighbors {
+		got = append(got, []string{n.LocalPort, n.RemoteSystem, n.Protocol})
+	}
+	want := [][]string{
+		{"GigabitEthernet1/0/1", "SW-02", NeighborProtocolLLDP},
+		{"TenGigabitEthernet1/1/1", "CE-01", NeighborProtocolLLDP},
+		{"TenGigabitEthernet1/1/2", "N9K-01", NeighborProtocolCDP},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("got %v", got)
+	}
+}
@@ -0,0 +1,169 @@
+package arkssh
+
+import (
+	"regexp"
+	"strings"
+	"sync"
+)
+
+// CommonNormalizeKey 对所有品牌、所有命令都生效的规则在注册表中使用的键
+const CommonNormalizeKey = "*"
+
+// ConfigBackupCmdPattern 判断命令是否为配置备份类命令（display current-configuration、show running-config等）的正则
+var ConfigBackupCmdPattern = `(?i)^\s*(dis(p(l(a(y)?)?)?)?\s+(cu\S*|sa\S*|startup\S*)|show\s+(run\S*|start\S*|configuration|full-configuration)|more\s+\S+\.(cfg|zip))\b`
+
+/**
+ * 配置回显的归一化规则，用于屏蔽每天都会变化的行（保存时间、软件版本、NTP时钟周期等），使配置比对保持稳定
+ * @attr Pattern:匹配行的正则，Replace:替换内容（支持$1引用分组），Drop:为true时直接丢弃匹配的行
+ */
+type NormalizeRule struct {
+	Pattern string `bson:"pattern" json:"pattern"`
+	Replace string `bson:"replace,omitempty" json:"replace,omitempty"`
+	Drop    bool   `bson:"drop,omitempty" json:"drop,omitempty"`
+}
+
+var (
+	normalizeRules = map[string][]NormalizeRule{
+		CommonNormalizeKey: {
+			//屏蔽保存时间不一样导致的每次配置比对都有差异
+			{Pattern: `^.*Last configuration was.*$`, Replace: "Last configuration updateed or saved"},
+		},
+		HUAWEI: {
+			{Pattern: `^!Software Version .*$`, Drop: true},
+			{Pattern: `^!Time: .*$`, Drop: true},
+			{Pattern: `(?i)^.*uptime is .*$`, Drop: true},
+		},
+		H3C: {
+			{Pattern: `^ version \d+\.\d+.*Release .*$`, Drop: true},
+			{Pattern: `(?i)^.*uptime is .*$`, Drop: true},
+		},
+		CISCO: {
+			{Pattern: `^! (Last configuration change|NVRAM config last updated) at .*$`, Replace: "! $1"},
+			{Pattern: `^!Time: .*$`, Drop: true},
+			{Pattern: `^Building configuration\.\.\.$`, Drop: true},
+			{Pattern: `^Current configuration : \d+ bytes$`, Drop: true},
+			{Pattern: `^ntp clock-period \d+$`, Drop: true},
+			{Pattern: `(?i)^\s*(valid(ity)? (from|to|start|end)|not (before|after))\b.*$`, Drop: true},
+			{Pattern: `(?i)^.*uptime is .*$`, Drop: true},
+		},
+	}
+	normalizeRulesLocker = new(sync.RWMutex)
+	regexpCache          = new(sync.Map)
+)
+
+/**
+ * 设置某个品牌的归一化规则，会覆盖该品牌已有的规则（包括内置规则）
+ * @param	brand，品牌名称（CommonNormalizeKey表示对所有品牌生效），rules，归一化规则
+ */
+func SetNormalizeRules(brand string, rules ...NormalizeRule) {
+	normalizeRulesLocker.Lock()
+	defer normalizeRulesLocker.Unlock()
+	normalizeRules[brand] = rules
+}
+
+/**
+ * 在某个品牌已有的归一化规则后追加规则
+ * @param	brand，品牌名称（CommonNormalizeKey表示对所有品牌生效），rules，归一化规则
+ */
+func AddNormalizeRules(brand string, rules ...NormalizeRule) {
+	normalizeRulesLocker.Lock()
+	defer normalizeRulesLocker.Unlock()
+	normalizeRules[brand] = append(normalizeRules[brand], rules...)
+}
+
+/**
+ * 获取某个品牌生效的归一化规则，通用规则在前，品牌规则在后
+ * @param	brand，品牌名称
+ * @return  规则切片的副本
+ */
+func GetNormalizeRules(brand string) []NormalizeRule {
+	normalizeRulesLocker.RLock()
+	defer normalizeRulesLocker.RUnlock()
+	rules := make([]NormalizeRule, 0, len(normalizeRules[CommonNormalizeKey])+len(normalizeRules[brand]))
+	rules = append(rules, normalizeRules[CommonNormalizeKey]...)
+	if brand != CommonNormalizeKey {
+		rules = append(rules, normalizeRules[brand]...)
+	}
+	return rules
+}
+
+/**
+ * 判断命令是否为配置备份类命令，只有这类命令的回显才会应用品牌的归一化规则
+ * @param	cmd，推送的命令
+ * @return  bool
+ */
+func IsConfigBackupCmd(cmd string) bool {
+	re := cachedRegexp(ConfigBackupCmdPattern)
+	if re == nil {
+		return false
+	}
+	return re.MatchString(cmd)
+}
+
+/**
+ * 对单行回显应用归一化规则
+ * @param	line，单行回显，rules，归一化规则
+ * @return  归一化后的行，bool为false表示该行需要丢弃
+ */
+func NormalizeLine(line string, rules []NormalizeRule) (string, bool) {
+	//回显的行尾可能带有\r，匹配时先去掉，处理完再补回，避免规则中的$无法匹配
+	suffix := ""
+	if strings.HasSuffix(line, "\r") {
+		line, suffix = strings.TrimSuffix(line, "\r"), "\r"
+	}
+	for _, rule := range rules {
+		re := cachedRegexp(rule.Pattern)
+		if re == nil || !re.MatchString(line) {
+			continue
+		}
+		if rule.Drop {
+			return "", false
+		}
+		line = re.ReplaceAllString(line, rule.Replace)
+	}
+	return line + suffix, true
+}
+
+/**
+ * 对整段配置文本应用归一化规则，供外部对已保存的配置进行比对前使用
+ * @param	brand，品牌名称，text，配置文本，extra，额外追加的规则
+ * @return  归一化后的配置文本
+ */
+func NormalizeConfig(brand, text string, extra ...NormalizeRule) string {
+	rules := append(GetNormalizeRules(brand), extra...)
+	lines := strings.Split(text, "\n")
+	kept := make([]string, 0, len(lines))
+	for _, line := range lines {
+		if newLine, ok := NormalizeLine(line, rules); ok {
+			kept = append(kept, newLine)
+		}
+	}
+	return strings.Join(kept, "\n")
+}
+
+/**
+ * 获取某条命令的回显应使用的归一化规则：通用规则始终生效，配置备份类命令额外应用品牌规则和设备自带的规则
+ * @param	cmd，推送的命令
+ * @return  规则切片
+ */
+func (d *Device) normalizeRules(cmd string) []NormalizeRule {
+	if !IsConfigBackupCmd(cmd) {
+		return GetNormalizeRules(CommonNormalizeKey)
+	}
+	return append(GetNormalizeRules(d.Brand), d.NormalizeRules...)
+}
+
+// 编译并缓存正则（归一化规则、提示符、错误信息共用），非法的正则只记录一次错误并返回nil
+func cachedRegexp(pattern string) *regexp.Regexp {
+	if cache, ok := regexpCache.Load(pattern); ok {
+		re, _ := cache.(*regexp.Regexp)
+		return re
+	}
+	re, err := regexp.Compile(pattern)
+	if err != nil {
+		LogError("正则编译失败:%s,pattern:%s", err.Error(), pattern)
+		re = nil
+	}
+	regexpCache.Store(pattern, re)
+	return re
+}
@@ -0,0 +1,32 @@
+package arkssh
+
+import "testing"
+
+func TestNormalizeConfig(t *testing.T) {
+	raw := "!Software Version V200R019C10SPC500\r\n!Last configuration was updated at 2026-10-18 10:00:00+08:00\r\n#\r\nsysname SW-01\r\n"
+	got := NormalizeConfig(HUAWEI, raw)
+	want := "Last configuration updateed or saved\r\n#\r\nsysname SW-01\r\n"
+	if got != want {
+		t.Fatalf("NormalizeConfig()=%q, want %q", got, want)
+	}
+
+	cisco := "Building configuration...\n! Last configuration change at 10:00:00 CST Sat Oct 18 2026 by admin\nntp clock-period 17179790\nhostname R1"
+	got = NormalizeConfig(CISCO, cisco)
+	want = "! Last configuration change\nhostname R1"
+	if got != want {
+		t.Fatalf("NormalizeConfig()=%q, want %q", got, want)
+	}
+}
+
+func TestIsConfigBackupCmd(t *testing.T) {
+	for _, cmd := range []string{"dis cu", "display current-configuration", "disp saved-configuration", "show run", "show running-config"} {
+		if !IsConfigBackupCmd(cmd) {
+			t.Errorf("IsConfigBackupCmd(%q)=false, want true", cmd)
+		}
+	}
+	for _, cmd := range []string{"disp interface", "show version", "dis clock"} {
+		if IsConfigBackupCmd(cmd) {
+			t.Errorf("IsConfigBackupCmd(%q)=true, want false", cmd)
+		}
+	}
+}
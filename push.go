@@ -0,0 +1,198 @@
+package arkssh
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+/**
+ * 配置推送的结果
+ * @attr IP:设备IP，Applied:已成功下发的配置行，FailedLine:执行失败的配置行，DeviceError:设备回显中的错误信息，
+ *       Saved:是否已保存配置，Output:推送过程的完整回显
+ */
+type PushResult struct {
+	IP          string   `bson:"ip" json:"ip"`
+	Applied     []string `bson:"applied,omitempty" json:"applied,omitempty"`
+	FailedLine  string   `bson:"failed_line,omitempty" json:"failed_line,omitempty"`
+	DeviceError string   `bson:"device_error,omitempty" json:"device_error,omitempty"`
+	Saved       bool     `bson:"saved" json:"saved"`
+	Output      string   `bson:"output,omitempty" json:"output,omitempty"`
+}
+
+/**
+ * 外部调用的统一方法，进入配置模式推送配置，失败时设备会退出配置模式
+ * @param	lines，配置行，不需要包含system-view/configure terminal等进入、退出配置模式的命令，save，推送成功后是否保存配置
+ * @return  推送结果和执行错误
+ */
+func (d *Device) PushConfig(lines []string, save bool) (*PushResult, error) {
+	result := &PushResult{IP: d.IP}
+	err := d.withSession(func(s *SSHSession) error {
+		pushed, err := s.PushConfig(lines, save, d.getterTimeout())
+		if pushed != nil {
+			pushed.IP = d.IP
+			result = pushed
+		}
+		return err
+	})
+	if err != nil {
+		LogError("推送配置错误:%s,IP:%s", err.Error(), d.IP)
+		return result, err
+	}
+	LogDebug("推送配置成功,IP:%s,行数:%d,是否保存:%v", d.IP, len(result.Applied), result.Saved)
+	return result, nil
+}
+
+/**
+ * 在当前SSH到的设备上推送配置：按Driver的ConfigEnterCmds进入配置模式，逐行下发并按ErrorPatterns检查回显，
+ * 全部成功后按ConfigExitCmds退出，save为true时再执行SaveCmds；任一行失败或回显超时则停止推送，按ConfigAbortCmds退出配置模式。
+ * 提示符为两阶段提交（如华为CE）时，退出前按TransactionCmds的Commit提交，失败时按Discard放弃候选配置
+ * @param	lines，配置行，其中与进入、退出配置模式相同的命令会被忽略，save，是否保存配置，timeout，读取单行回显的超时时间（秒）
+ * @return  推送结果和执行错误，失败时结果中记录失败的行和设备的错误信息
+ */
+func (s *SSHSession) PushConfig(lines []string, save bool, timeout int) (*PushResult, error) {
+	brand := s.GetSSHBrand()
+	driver := s.Driver()
+	if driver == nil {
+		return nil, errors.New("无法识别设备品牌，不能推送配置")
+	}
+	if len(driver.ConfigEnterCmds()) == 0 {
+		return nil, fmt.Errorf("品牌%s不支持配置模式", brand)
+	}
+	result := &PushResult{}
+	var output strings.Builder
+	defer func() { result.Output = output.String() }()
+	//进入配置模式，根据提示符判断是否需要提交
+	twoStage := false
+	for _, cmd := range driver.ConfigEnterCmds() {
+		res, errLine, err := s.sendConfigLine(driver, cmd, timeout)
+		output.WriteString(res)
+		if err != nil {
+			result.FailedLine, result.DeviceError = cmd, errLine
+			s.abortConfig(driver, timeout, &output)
+			return result, fmt.Errorf("进入配置模式失败:%v", err)
+		}
+		twoStage = isTwoStage(driver, res)
+	}
+	commit := driver.TransactionCmds().Commit
+	if twoStage && len(commit) == 0 {
+		s.abortConfig(driver, timeout, &output)
+		return result, fmt.Errorf("品牌%s为两阶段提交，但未定义提交命令", brand)
+	}
+	//两阶段提交时先放弃候选配置，否则退出配置模式时设备会询问是否提交
+	abort := func() {
+		if twoStage {
+			s.sendConfirmCmds(driver, driver.TransactionCmds().Discard, timeout, &output)
+		}
+		s.abortConfig(driver, timeout, &output)
+	}
+	for _, line := range configPushLines(driver, lines) {
+		res, errLine, err := s.sendConfigLine(driver, line, timeout)
+		output.WriteString(res)
+		if err != nil {
+			result.FailedLine, result.DeviceError = line, errLine
+			abort()
+			return result, fmt.Errorf("配置下发失败:%s,%v", line, err)
+		}
+		result.Applied = append(result.Applied, line)
+	}
+	if twoStage {
+		if errLine, err := s.sendConfirmCmds(driver, commit, timeout, &output); err != nil {
+			result.FailedLine, result.DeviceError = strings.Join(commit, ";"), errLine
+			abort()
+			return result, fmt.Errorf("提交配置失败:%v", err)
+		}
+	}
+	//退出配置模式，需要提交的品牌（如junos、paloalto）在此时提交，提交失败同样视为失败
+	for _, cmd := range driver.ConfigExitCmds() {
+		res, errLine, err := s.sendConfigLine(driver, cmd, timeout)
+		output.WriteString(res)
+		if err != nil {
+			result.FailedLine, result.DeviceError = cmd, errLine
+			s.abortConfig(driver, timeout, &output)
+			return result, fmt.Errorf("退出配置模式失败:%v", err)
+		}
+	}
+	if !save {
+		return result, nil
+	}
+	return result, s.saveConfig(driver, timeout, result, &output)
+}
+
+// 按Driver的SaveCmds保存配置，保存命令包含确认输入（如华为的save、Y），需要一次性写入
+func (s *SSHSession) saveConfig(driver Driver, timeout int, result *PushResult, output *strings.Builder) error {
+	if len(driver.SaveCmds()) == 0 {
+		return fmt.Errorf("品牌%s不支持保存配置", driver.Name())
+	}
+	s.WriteChannel(driver.SaveCmds()...)
+	res, ok := s.ReadChannelTiming(timeout)
+	output.WriteString(res)
+	if line, failed := MatchDriverError(driver, res); failed {
+		result.DeviceError = line
+		return errors.New("保存配置失败:" + line)
+	}
+	if !ok {
+		return errors.New("保存配置超时")
+	}
+	result.Saved = true
+	return nil
+}
+
+// 下发一行配置，返回回显、匹配到的错误行，回显中有错误或读取超时时返回错误
+func (s *SSHSession) sendConfigLine(driver Driver, line string, timeout int) (string, string, error) {
+	s.WriteChannel(line)
+	res, ok := s.ReadChannelTiming(timeout)
+	if errLine, failed := MatchDriverError(driver, res); failed {
+		return res, errLine, errors.New(errLine)
+	}
+	if !ok {
+		return res, "", errors.New("读取命令回显超时")
+	}
+	return res, "", nil
+}
+
+// 推送失败后退出配置模式，尽量不让缓存的会话停留在配置模式中；命令报错时改用Driver定义的替代命令（如旧版本PAN-OS没有revert config）
+func (s *SSHSession) abortConfig(driver Driver, timeout int, output *strings.Builder) {
+	for _, cmd := range driver.ConfigAbortCmds() {
+		s.WriteChannel(cmd)
+		res, _ := s.ReadChannelTiming(timeout)
+		output.WriteString(res)
+		if fallback := driver.ConfigAbortFallbackCmd(cmd); fallback != "" {
+			if _, failed := MatchDriverError(driver, res); failed {
+				s.WriteChannel(fallback)
+				res, _ = s.ReadChannelTiming(timeout)
+				output.WriteString(res)
+			}
+		}
+	}
+}
+
+// 根据进入配置模式后的提示符判断是否为两阶段提交（如华为CE的[~HOSTNAME]），一阶段生效的设备（如华为S系列）提示符为[HOSTNAME]
+func isTwoStage(driver Driver, res string) bool {
+	if driver.TwoStagePattern() == "" {
+		return false
+	}
+	re := cachedRegexp(driver.TwoStagePattern())
+	return re != nil && re.MatchString(res)
+}
+
+// 去掉配置行的首尾空白、空行，以及调用方自行加在开头的进入配置模式命令和末尾的退出配置模式命令；
+// 只去掉开头与ConfigEnterCmds、末尾与ConfigExitCmds一致的部分，中间的行（如paloalto返回上一层的exit）原样下发
+func configPushLines(driver Driver, lines []string) []string {
+	kept := make([]string, 0, len(lines))
+	for _, line := range lines {
+		line = strings.TrimSpace(line)
+		if line == "" {
+			continue
+		}
+		kept = append(kept, line)
+	}
+	enter, exit := driver.ConfigEnterCmds(), driver.ConfigExitCmds()
+	for i := 0; i < len(enter) && len(kept) > 0 && kept[0] == enter[i]; i++ {
+		kept = kept[1:]
+	}
+	for i := len(exit) - 1; i >= 0 && len(kept) > 0 && kept[len(kept)-1] == exit[i]; i-- {
+		kept = kept[:len(kept)-1]
+	}
+	return kept
+}
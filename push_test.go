@@ -0,0 +1,150 @@
+package arkssh
+
+import (
+	"strings"
+	"testing"
+)
+
+// 按命令返回预设回显的会话，记录收到的命令，用于测试推送流程
+func newScriptedSession(brand string, reply func(cmd string) string) (*SSHSession, *[]string) {
+	s := &SSHSession{in: make(chan string), out: make(chan string, 100), brand: brand}
+	var received []string
+	go func() {
+		for cmd := range s.in {
+			received = append(received, cmd)
+			s.out <- cmd + "\r\n" + reply(cmd)
+		}
+	}()
+	return s, &received
+}
+
+// 华为设备的回显：system-view后进入系统视图，interface进入接口视图，bad开头的命令报错
+func huaweiReply(cmd string) string {
+	switch {
+	case cmd == "system-view":
+		return "Enter system view, return user view with return command.\r\n[SW-01]"
+	case strings.HasPrefix(cmd, "interface "):
+		return "\r\n[SW-01-GigabitEthernet1/0/1]"
+	case strings.HasPrefix(cmd, "bad"):
+		return "                  ^\r\nError: Unrecognized command found at '^' position.\r\n[SW-01]"
+	case cmd == "return" || cmd == "Y":
+		return "\r\n<SW-01>"
+	case cmd == "save":
+		return "Are you sure to continue?[Y/N]:"
+	default:
+		return "\r\n[SW-01]"
+	}
+}
+
+func TestPushConfig(t *testing.T) {
+	s, received := newScriptedSession(HUAWEI, huaweiReply)
+	result, err := s.PushConfig([]string{"system-view", " interface GigabitEthernet1/0/1", " description to-CE-03", "", "return"}, true, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []string{"system-view", "interface GigabitEthernet1/0/1", "description to-CE-03", "return", "save", "Y"}
+	if strings.Join(*received, "|") != strings.Join(want, "|") {
+		t.Fatalf("sent %q, want %q", *received, want)
+	}
+	if len(result.Applied) != 2 || !result.Saved {
+		t.Fatalf("result got %+v", result)
+	}
+}
+
+func TestPushConfigAbort(t *testing.T) {
+	s, received := newScriptedSession(HUAWEI, huaweiReply)
+	result, err := s.PushConfig([]string{"vlan 10", "bad command", "vlan 20"}, true, 1)
+	if err == nil {
+		t.Fatal("push should fail")
+	}
+	//失败后不再下发后续的行，不保存，并退出配置模式
+	want := []string{"system-view", "vlan 10", "bad command", "return"}
+	if strings.Join(*received, "|") != strings.Join(want, "|") {
+		t.Fatalf("sent %q, want %q", *received, want)
+	}
+	if result.FailedLine != "bad command" || !strings.HasPrefix(result.DeviceError, "Error: Unrecognized") || result.Saved || len(result.Applied) != 1 {
+		t.Fatalf("result got %+v", result)
+	}
+}
+
+func TestConfigAbortCmds(t *testing.T) {
+	juniper, _ := GetDriver(JUNIPER)
+	if got := juniper.ConfigAbortCmds(); len(got) != 2 || got[0] != "rollback 0" {
+		t.Fatalf("juniper abort got %v", got)
+	}
+	cisco, _ := GetDriver(CISCO)
+	if got := cisco.ConfigAbortCmds(); len(got) != 1 || got[0] != "end" {
+		t.Fatalf("cisco abort got %v", got)
+	}
+}
+
+// 华为CE两阶段提交的回显：系统视图提示符为[~CE-01]，有未提交的配置时为[*CE-01]
+func huaweiCEReply(cmd string) string {
+	switch {
+	case cmd == "system-view", cmd == "commit", cmd == "clear configuration candidate":
+		return "\r\n[~CE-01]"
+	case strings.HasPrefix(cmd, "bad"):
+		return "                  ^\r\nError: Unrecognized command found at '^' position.\r\n[*CE-01]"
+	case cmd == "return":
+		return "\r\n<CE-01>"
+	default:
+		return "\r\n[*CE-01]"
+	}
+}
+
+func TestPushConfigTwoStage(t *testing.T) {
+	s, received := newScriptedSession(HUAWEI, huaweiCEReply)
+	if _, err := s.PushConfig([]string{"vlan 10"}, false, 1); err != nil {
+		t.Fatal(err)
+	}
+	//两阶段提交时退出前先提交
+	want := []string{"system-view", "vlan 10", "commit", "return"}
+	if strings.Join(*received, "|") != strings.Join(want, "|") {
+		t.Fatalf("sent %q, want %q", *received, want)
+	}
+	//失败时放弃候选配置后再退出，不会停在是否提交的询问上
+	s, received = newScriptedSession(HUAWEI, huaweiCEReply)
+	if _, err := s.PushConfig([]string{"vlan 10", "bad command"}, false, 1); err == nil {
+		t.Fatal("push should fail")
+	}
+	want = []string{"system-view", "vlan 10", "bad command", "clear configuration candidate", "return"}
+	if strings.Join(*received, "|") != strings.Join(want, "|") {
+		t.Fatalf("sent %q, want %q", *received, want)
+	}
+}
+
+func TestPushConfigAbortFallback(t *testing.T) {
+	s, received := newScriptedSession(PALOALTO, func(cmd string) string {
+		switch {
+		case strings.HasPrefix(cmd, "set bad"), cmd == "revert config":
+			return "Unknown command: " + cmd + "\r\n[edit]\r\nadmin@PA-01#"
+		case cmd == "exit":
+			return "\r\nadmin@PA-01>"
+		default:
+			return "\r\n[edit]\r\nadmin@PA-01#"
+		}
+	})
+	if _, err := s.PushConfig([]string{"set bad"}, false, 1); err == nil {
+		t.Fatal("push should fail")
+	}
+	//不支持revert config的旧版本改为从运行配置加载，放弃候选配置后再退出
+	want := []string{"configure", "set bad", "revert config", "load config from running-config.xml", "exit"}
+	if strings.Join(*received, "|") != strings.Join(want, "|") {
+		t.Fatalf("sent %q, want %q", *received, want)
+	}
+}
+
+func TestConfigPushLines(t *testing.T) {
+	paloalto, _ := GetDriver(PALOALTO)
+	//中间的exit用于返回上一层，保留；开头的configure和末尾的commit、exit去掉
+	got := configPushLines(paloalto, []string{"configure", "edit rulebase security", "set rules allow-web action allow", "exit", "set deviceconfig system hostname PA-01", "commit", "exit"})
+	want := []string{"edit rulebase security", "set rules allow-web action allow", "exit", "set deviceconfig system hostname PA-01"}
+	if strings.Join(got, "|") != strings.Join(want, "|") {
+		t.Fatalf("paloalto got %q, want %q", got, want)
+	}
+	huawei, _ := GetDriver(HUAWEI)
+	got = configPushLines(huawei, []string{"system-view", " interface GigabitEthernet1/0/1", "", " description up", "return"})
+	if strings.Join(got, "|") != "interface GigabitEthernet1/0/1|description up" {
+		t.Fatalf("huawei got %q", got)
+	}
+}
@@ -0,0 +1,245 @@
+package arkssh
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// 设备分类的字段，用于GroupDevicesByRole
+const (
+	RoleFieldRole = "role"
+	RoleFieldSite = "site"
+	RoleFieldPod  = "pod"
+	RoleFieldRack = "rack"
+)
+
+/**
+ * 根据主机名划分设备角色的规则，Pattern中的命名分组role、site、pod、rack直接作为对应字段的值
+ * @attr Pattern:匹配主机名的正则，Role/Site/Pod/Rack:不为空时作为对应字段的值，优先于命名分组，支持$1、${site}引用分组
+ */
+type RoleRule struct {
+	Pattern string `bson:"pattern" json:"pattern"`
+	Role    string `bson:"role,omitempty" json:"role,omitempty"`
+	Site    string `bson:"site,omitempty" json:"site,omitempty"`
+	Pod     string `bson:"pod,omitempty" json:"pod,omitempty"`
+	Rack    string `bson:"rack,omitempty" json:"rack,omitempty"`
+}
+
+/**
+ * 设备的分类结果
+ * @attr Hostname:从登录提示符中取出的主机名，Role:角色，Site:站点，Pod:POD，Rack:机柜，Rule:命中的规则，没有规则命中时除Hostname外均为空
+ */
+type DeviceRole struct {
+	Hostname string `bson:"hostname,omitempty" json:"hostname,omitempty"`
+	Role     string `bson:"role,omitempty" json:"role,omitempty"`
+	Site     string `bson:"site,omitempty" json:"site,omitempty"`
+	Pod      string `bson:"pod,omitempty" json:"pod,omitempty"`
+	Rack     string `bson:"rack,omitempty" json:"rack,omitempty"`
+	Rule     string `bson:"rule,omitempty" json:"rule,omitempty"`
+}
+
+var (
+	// 内置规则与role模板保持一致：主机名以角色字母（S、T、I、M、MR、Mir、T_）加可选的C/Spine结尾，后缀为站点
+	roleRules = []RoleRule{
+		{Pattern: `(?P<role>MR|Mir|T_|S|T|I|M)(?:C|[Ss]pine)?\.(?P<site>TGY|HeY)$`},
+	}
+	roleRulesLocker = new(sync.RWMutex)
+	// 从提示符中取出主机名：<host>、[host]、[~host]、[user@host dir]、user@host>、host#、host(config)#
+	promptHostnamePatterns = []*regexp.Regexp{
+		regexp.MustCompile(`^<([^>]+)>$`),
+		regexp.MustCompile(`^\[(?:[^\]@\s]+@)?([^\]\s]+)[^\]]*\][#$]?$`),
+		regexp.MustCompile(`^(?:\S+@)?([^\s@#>$(]+)(?:\([^)]*\))?[#>$%]$`),
+	}
+)
+
+/**
+ * 设置主机名分类规则，会覆盖已有的规则（包括内置规则）
+ * @param	rules，分类规则，按顺序匹配，第一条命中的规则生效
+ * @return  规则中的正则错误，出错时不修改已有规则
+ */
+func SetRoleRules(rules ...RoleRule) error {
+	if err := validateRoleRules(rules); err != nil {
+		return err
+	}
+	roleRulesLocker.Lock()
+	defer roleRulesLocker.Unlock()
+	roleRules = rules
+	return nil
+}
+
+/**
+ * 在已有的主机名分类规则后追加规则
+ * @param	rules，分类规则
+ * @return  规则中的正则错误，出错时不修改已有规则
+ */
+func AddRoleRules(rules ...RoleRule) error {
+	if err := validateRoleRules(rules); err != nil {
+		return err
+	}
+	roleRulesLocker.Lock()
+	defer roleRulesLocker.Unlock()
+	roleRules = append(roleRules, rules...)
+	return nil
+}
+
+/**
+ * 获取当前生效的主机名分类规则
+ * @return  规则切片的副本
+ */
+func GetRoleRules() []RoleRule {
+	roleRulesLocker.RLock()
+	defer roleRulesLocker.RUnlock()
+	return append([]RoleRule(nil), roleRules...)
+}
+
+// 校验规则的正则能够编译
+func validateRoleRules(rules []RoleRule) error {
+	for _, rule := range rules {
+		if _, err := regexp.Compile(rule.Pattern); err != nil {
+			return fmt.Errorf("分类规则的正则错误:%s,err:%v", rule.Pattern, err)
+		}
+	}
+	return nil
+}
+
+/**
+ * 从登录回显中取出主机名，使用最后一个非空行（提示符）
+ * @param	output，登录后的回显或提示符
+ * @return  主机名，无法识别时为空
+ */
+func PromptHostname(output string) string {
+	lines := strings.Split(strings.ReplaceAll(output, "\r", ""), "\n")
+	for i := len(lines) - 1; i >= 0; i-- {
+		prompt := strings.TrimSpace(lines[i])
+		if prompt == "" {
+			continue
+		}
+		for _, re := range promptHostnamePatterns {
+			if match := re.FindStringSubmatch(prompt); match != nil {
+				return strings.TrimLeft(match[1], "~*")
+			}
+		}
+		return ""
+	}
+	return ""
+}
+
+/**
+ * 按规则对主机名分类，extra中的规则优先于全局规则
+ * @param	hostname，主机名，extra，额外的规则（如Device.RoleRules）
+ * @return  分类结果，没有规则命中时只有Hostname；extra中的正则错误会被跳过
+ */
+func ClassifyHostname(hostname string, extra ...RoleRule) *DeviceRole {
+	role := &DeviceRole{Hostname: hostname}
+	if hostname == "" {
+		return role
+	}
+	rules := append(append(make([]RoleRule, 0, len(extra)), extra...), GetRoleRules()...)
+	for _, rule := range rules {
+		re := cachedRegexp(rule.Pattern)
+		if re == nil {
+			continue
+		}
+		match := re.FindStringSubmatchIndex(hostname)
+		if match == nil {
+			continue
+		}
+		role.Role = roleField(re, hostname, match, rule.Role, RoleFieldRole)
+		role.Site = roleField(re, hostname, match, rule.Site, RoleFieldSite)
+		role.Pod = roleField(re, hostname, match, rule.Pod, RoleFieldPod)
+		role.Rack = roleField(re, hostname, match, rule.Rack, RoleFieldRack)
+		role.Rule = rule.Pattern
+		return role
+	}
+	return role
+}
+
+// 规则中指定了值时展开其中的分组引用，否则取同名的命名分组
+func roleField(re *regexp.Regexp, hostname string, match []int, template, group string) string {
+	if template != "" {
+		return string(re.ExpandString(nil, template, hostname, match))
+	}
+	if i := re.SubexpIndex(group); i >= 0 && match[2*i] >= 0 {
+		return hostname[match[2*i]:match[2*i+1]]
+	}
+	return ""
+}
+
+/**
+ * 根据登录时捕获的提示符对当前SSH到的设备分类，不发送任何命令
+ * @param	extra，额外的规则，优先于全局规则
+ * @return  分类结果
+ */
+func (s *SSHSession) ClassifyRole(extra ...RoleRule) *DeviceRole {
+	return ClassifyHostname(PromptHostname(s.loginOutput), extra...)
+}
+
+/**
+ * 外部调用的统一方法，登录设备并根据提示符分类，结果写入d.Role；RunCmdWithBrand也会顺带写入d.Role
+ * @return 分类结果和登录错误
+ */
+func (d *Device) ClassifyRole() (*DeviceRole, error) {
+	err := d.withSession(func(s *SSHSession) error {
+		d.Role = s.ClassifyRole(d.RoleRules...)
+		return nil
+	})
+	if err != nil {
+		LogError("设备分类错误:%s,IP:%s", err.Error(), d.IP)
+		return nil, err
+	}
+	LogDebug("设备分类成功,IP:%s,hostname:%s,role:%s,site:%s", d.IP, d.Role.Hostname, d.Role.Role, d.Role.Site)
+	return d.Role, nil
+}
+
+/**
+ * 按分类字段对设备分组，便于按角色、站点分批调用BulkRunCmd，分组内保持原有顺序
+ * @param	devices，已分类的设备（d.Role为空的设备各字段视为空），fields，分组字段（RoleFieldRole等），多个字段的值用"/"连接作为分组的key
+ * @return  以分组key为键的设备切片，设备为副本，执行结果写在分组的切片中
+ */
+func GroupDevicesByRole(devices []Device, fields ...string) map[string][]Device {
+	if len(fields) == 0 {
+		fields = []string{RoleFieldRole}
+	}
+	groups := make(map[string][]Device)
+	for _, d := range devices {
+		role := d.Role
+		if role == nil {
+			role = &DeviceRole{}
+		}
+		values := make([]string, 0, len(fields))
+		for _, field := range fields {
+			values = append(values, role.Field(field))
+		}
+		key := strings.Join(values, "/")
+		groups[key] = append(groups[key], d)
+	}
+	return groups
+}
+
+// Field 按字段名称取分类结果中的值，未知的字段返回空
+func (r *DeviceRole) Field(field string) string {
+	switch field {
+	case RoleFieldRole:
+		return r.Role
+	case RoleFieldSite:
+		return r.Site
+	case RoleFieldPod:
+		return r.Pod
+	case RoleFieldRack:
+		return r.Rack
+	}
+	return ""
+}
+
+// RoleGroupKeys 返回排序后的分组key，便于按固定顺序分批执行
+func RoleGroupKeys(groups map[string][]Device) []string {
+	keys := make([]string, 0, len(groups))
+	for key := range groups {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
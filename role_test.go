@@ -0,0 +1,69 @@
+package arkssh
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestPromptHostname(t *testing.T) {
+	cases := map[string]string{
+		"Info: The max number of VTY users is 10\r\n<SW-01.TGY>": "SW-01.TGY",
+		"\r\n[~CE-01-S.HeY]": "CE-01-S.HeY",
+		"\r\nR1#":            "R1",
+		"\r\nR1(config)#":    "R1",
+		"admin@QFX-01> ":     "QFX-01",
+		"Last login: Sat Oct 18 10:00:00 2026\r\n[root@server-01 ~]# ": "server-01",
+		"":          "",
+		"Password:": "",
+	}
+	for output, want := range cases {
+		if got := PromptHostname(output); got != want {
+			t.Fatalf("%q got %q, want %q", output, got, want)
+		}
+	}
+}
+
+func TestClassifyHostname(t *testing.T) {
+	//内置规则与role模板一致
+	if got := ClassifyHostname("BJ-01-MRC.TGY"); got.Role != "MR" || got.Site != "TGY" {
+		t.Fatalf("builtin rule got %+v", got)
+	}
+	if got := ClassifyHostname("leaf-01"); got.Role != "" || got.Rule != "" || got.Hostname != "leaf-01" {
+		t.Fatalf("no rule should match, got %+v", got)
+	}
+
+	extra := []RoleRule{
+		{Pattern: `^(?P<site>[A-Z]+\d*)-(?P<pod>P\d+)-(?P<rack>R\d+)-LEAF`, Role: "leaf"},
+		{Pattern: `^([A-Z]+)-SPINE-\d+$`, Role: "spine", Site: "$1"},
+	}
+	want := &DeviceRole{Hostname: "SH01-P2-R15-LEAF-1", Role: "leaf", Site: "SH01", Pod: "P2", Rack: "R15", Rule: extra[0].Pattern}
+	if got := ClassifyHostname("SH01-P2-R15-LEAF-1", extra...); !reflect.DeepEqual(got, want) {
+		t.Fatalf("got %+v", got)
+	}
+	if got := ClassifyHostname("GZ-SPINE-01", extra...); got.Role != "spine" || got.Site != "GZ" {
+		t.Fatalf("got %+v", got)
+	}
+	if err := AddRoleRules(RoleRule{Pattern: `(`}); err == nil {
+		t.Fatalf("invalid pattern should be rejected")
+	}
+}
+
+func TestGroupDevicesByRole(t *testing.T) {
+	devices := []Device{
+		{IP: "10.0.0.1", Role: &DeviceRole{Role: "leaf", Site: "SH01"}},
+		{IP: "10.0.0.2", Role: &DeviceRole{Role: "spine", Site: "SH01"}},
+		{IP: "10.0.0.3", Role: &DeviceRole{Role: "leaf", Site: "GZ"}},
+		{IP: "10.0.0.4"},
+		{IP: "10.0.0.5", Role: &DeviceRole{Role: "leaf", Site: "SH01"}},
+	}
+	groups := GroupDevicesByRole(devices, RoleFieldSite, RoleFieldRole)
+	if keys := RoleGroupKeys(groups); !reflect.DeepEqual(keys, []string{"/", "GZ/leaf", "SH01/leaf", "SH01/spine"}) {
+		t.Fatalf("keys got %v", keys)
+	}
+	if group := groups["SH01/leaf"]; len(group) != 2 || group[0].IP != "10.0.0.1" || group[1].IP != "10.0.0.5" {
+		t.Fatalf("group got %+v", group)
+	}
+	if groups := GroupDevicesByRole(devices); len(groups["leaf"]) != 3 {
+		t.Fatalf("default group got %+v", groups)
+	}
+}
@@ -0,0 +1,184 @@
+package arkssh
+
+import (
+	"net/netip"
+	"strings"
+	"unicode"
+)
+
+// 路由表对应的textfsm模板名称
+const RoutesTemplate = "routes"
+
+// 统一后的路由协议
+const (
+	RouteProtocolConnected = "connected"
+	RouteProtocolLocal     = "local"
+	RouteProtocolStatic    = "static"
+	RouteProtocolOSPF      = "ospf"
+	RouteProtocolBGP       = "bgp"
+	RouteProtocolISIS      = "isis"
+	RouteProtocolRIP       = "rip"
+	RouteProtocolEIGRP     = "eigrp"
+)
+
+// 思科路由表的协议代码
+var ciscoRouteCodes = map[string]string{
+	"C": RouteProtocolConnected, "L": RouteProtocolLocal, "S": RouteProtocolStatic, "O": RouteProtocolOSPF,
+	"B": RouteProtocolBGP, "i": RouteProtocolISIS, "R": RouteProtocolRIP, "D": RouteProtocolEIGRP,
+}
+
+/**
+ * 规范化后的路由
+ * @attr Prefix:目的网段（如10.2.0.0/16），Protocol:统一后的协议（connected/static/ospf/bgp等），RawProtocol:设备显示的协议（如O_ASE2、O IA），
+ *       Preference:优先级（思科为管理距离），Metric:开销，NextHops:下一跳（等价路由有多个），VRF:所属VPN实例/VRF，公网为空
+ */
+type Route struct {
+	Prefix      string    `bson:"prefix" json:"prefix"`
+	Protocol    string    `bson:"protocol" json:"protocol"`
+	RawProtocol string    `bson:"raw_protocol,omitempty" json:"raw_protocol,omitempty"`
+	Preference  int       `bson:"preference" json:"preference"`
+	Metric      int       `bson:"metric" json:"metric"`
+	NextHops    []NextHop `bson:"next_hops" json:"next_hops"`
+	VRF         string    `bson:"vrf,omitempty" json:"vrf,omitempty"`
+}
+
+/**
+ * 路由的下一跳
+ * @attr Address:下一跳地址，直连路由可能为空，Interface:规范化的出接口
+ */
+type NextHop struct {
+	Address   string `bson:"address,omitempty" json:"address,omitempty"`
+	Interface string `bson:"interface,omitempty" json:"interface,omitempty"`
+}
+
+// routes模板的一条记录，每个下一跳一条记录
+type routeRecord struct {
+	Prefix     string `textfsm:"prefix"`
+	Protocol   string `textfsm:"protocol"`
+	Preference int    `textfsm:"preference"`
+	Metric     int    `textfsm:"metric"`
+	NextHop    string `textfsm:"next_hop"`
+	Interface  string `textfsm:"interface"`
+}
+
+/**
+ * 外部调用的统一方法，获取设备的IPv4路由表
+ * @param	vrf，VPN实例/VRF名称，为空表示公网路由表
+ * @return 规范化后的路由和执行错误
+ */
+func (d *Device) GetRoutes(vrf string) ([]Route, error) {
+	var routes []Route
+	err := d.withSession(func(s *SSHSession) error {
+		var err error
+		routes, err = s.GetRoutes(vrf, d.getterTimeout())
+		return err
+	})
+	if err != nil {
+		LogError("获取路由表错误:%s,IP:%s,vrf:%s", err.Error(), d.IP, vrf)
+		return nil, err
+	}
+	LogDebug("获取路由表成功,IP:%s,vrf:%s,路由数量:%d", d.IP, vrf, len(routes))
+	return routes, nil
+}
+
+/**
+ * 获取当前SSH到的设备的IPv4路由表，执行的命令由Driver的GetterRoutes定义，指定vrf时按Driver的VRFCmd追加VPN实例/VRF
+ * @param	vrf，VPN实例/VRF名称，为空表示公网路由表，timeout，读取单条命令回显的超时时间（秒）
+ * @return 规范化后的路由和执行错误
+ */
+func (s *SSHSession) GetRoutes(vrf string, timeout int) ([]Route, error) {
+	results, err := s.runGetterInVRF(GetterRoutes, vrf, timeout)
+	if err != nil {
+		return nil, err
+	}
+	return BuildRoutes(s.brand, vrf, results[RoutesTemplate])
+}
+
+/**
+ * 将routes模板的解析结果转换为规范化的路由，相邻的同一网段、同一协议的记录合并为一条等价路由
+ * @param	brand，品牌名称，vrf，VPN实例/VRF名称，records，routes模板的解析结果
+ * @return  路由和转换错误
+ */
+func BuildRoutes(brand, vrf string, records []map[string]interface{}) ([]Route, error) {
+	rows, _, err := ParseInto[routeRecord](records)
+	if err != nil {
+		return nil, err
+	}
+	routes := make([]Route, 0, len(rows))
+	for _, row := range rows {
+		hop := NextHop{Address: row.NextHop, Interface: CanonicalPortName(brand, row.Interface)}
+		if n := len(routes); n > 0 && routes[n-1].Prefix == row.Prefix && routes[n-1].RawProtocol == row.Protocol {
+			routes[n-1].NextHops = append(routes[n-1].NextHops, hop)
+			continue
+		}
+		routes = append(routes, Route{
+			Prefix:      row.Prefix,
+			Protocol:    normalizeRouteProtocol(brand, row.Protocol),
+			RawProtocol: row.Protocol,
+			Preference:  row.Preference,
+			Metric:      row.Metric,
+			NextHops:    []NextHop{hop},
+			VRF:         vrf,
+		})
+	}
+	return routes, nil
+}
+
+// 统一路由协议，华为、华三为Direct、O_ASE、IBGP、ISIS-L1等，思科为协议代码（S*、O IA等）
+func normalizeRouteProtocol(brand, raw string) string {
+	if brand == CISCO {
+		//协议代码为开头的字母，之后是候选默认路由的*和子类型（如O*E2、O IA）
+		code := strings.TrimSpace(raw)
+		if i := strings.IndexFunc(code, func(r rune) bool { return !unicode.IsLetter(r) }); i >= 0 {
+			code = code[:i]
+		}
+		if code == "" {
+			return ""
+		}
+		if protocol, ok := ciscoRouteCodes[code]; ok {
+			return protocol
+		}
+		return strings.ToLower(raw)
+	}
+	lower := strings.ToLower(raw)
+	switch {
+	case lower == "direct":
+		return RouteProtocolConnected
+	case lower == "static":
+		return RouteProtocolStatic
+	case strings.HasPrefix(lower, "o_") || strings.HasPrefix(lower, "ospf"):
+		return RouteProtocolOSPF
+	case strings.Contains(lower, "bgp"):
+		return RouteProtocolBGP
+	case strings.HasPrefix(lower, "is"):
+		return RouteProtocolISIS
+	case strings.HasPrefix(lower, "rip"):
+		return RouteProtocolRIP
+	default:
+		return lower
+	}
+}
+
+/**
+ * 按最长掩码匹配查找IP命中的路由，用于排查某个地址的转发路径
+ * @param	routes，路由表，ip，IPv4地址
+ * @return  命中的路由，没有命中或IP格式错误时为nil
+ */
+func LongestMatch(routes []Route, ip string) *Route {
+	addr, err := netip.ParseAddr(ip)
+	if err != nil {
+		return nil
+	}
+	var best *Route
+	bestBits := -1
+	for i := range routes {
+		prefix, err := netip.ParsePrefix(routes[i].Prefix)
+		if err != nil || !prefix.Contains(addr) {
+			continue
+		}
+		if prefix.Bits() > bestBits {
+			best, bestBits = &routes[i], prefix.Bits()
+		}
+	}
+	return best
+}
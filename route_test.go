@@ -0,0 +1,100 @@
+package arkssh
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestBuildRoutes(t *testing.T) {
+	routes, err := BuildRoutes(HUAWEI, "", fixtureRecords(t, HUAWEI, RoutesTemplate, "public"))
+	if err != nil {
+		t.Fatalf("BuildRoutes err:%v", err)
+	}
+	//等价路由合并为一条
+	want := Route{Prefix: "10.2.0.0/16", Protocol: RouteProtocolOSPF, RawProtocol: "OSPF", Preference: 10, Metric: 2, NextHops: []NextHop{
+		{Address: "10.1.1.2", Interface: "Vlanif100"},
+		{Address: "10.1.1.3", Interface: "Vlanif200"},
+	}}
+	if len(routes) != 6 || !reflect.DeepEqual(routes[3], want) || routes[4].Protocol != RouteProtocolBGP || routes[1].Protocol != RouteProtocolConnected {
+		t.Fatalf("got %+v", routes)
+	}
+
+	routes, err = BuildRoutes(H3C, "vpn1", fixtureRecords(t, H3C, RoutesTemplate, "vpn"))
+	if err != nil {
+		t.Fatalf("BuildRoutes err:%v", err)
+	}
+	if len(routes) != 4 || routes[2].VRF != "vpn1" || len(routes[2].NextHops) != 2 || routes[3].Protocol != RouteProtocolOSPF || routes[3].RawProtocol != "O_ASE2" {
+		t.Fatalf("got %+v", routes)
+	}
+	if routes[0].NextHops[0].Interface != "Vlan-interface100" {
+		t.Fatalf("interface got %+v", routes[0].NextHops)
+	}
+}
+
+func TestBuildRoutesCisco(t *testing.T) {
+	routes, err := BuildRoutes(CISCO, "", fixtureRecords(t, CISCO, RoutesTemplate, "ios"))
+	if err != nil {
+		t.Fatalf("BuildRoutes err:%v", err)
+	}
+	var got [][]string
+	for _, route := range routes {
+		got = append(got, []string{route.Prefix, route.Protocol, route.NextHops[len(route.NextHops)-1].Address, route.NextHops[len(route.NextHops)-1].Interface})
+	}
+	want := [][]string{
+		{"0.0.0.0/0", RouteProtocolStatic, "10.1.1.254", ""},
+		{"10.1.1.0/24", RouteProtocolConnected, "", "Vlan100"},
+		{"10.1.1.1/32", RouteProtocolLocal, "", "Vlan100"},
+		{"10.2.0.0/16", RouteProtocolOSPF, "10.1.1.3", "Vlan200"},
+		{"10.3.0.0/16", RouteProtocolOSPF, "10.1.1.2", "Vlan100"},
+		{"10.4.0.0/16", RouteProtocolStatic, "10.1.1.5", "Vlan100"},
+		{"172.16.0.0/16", RouteProtocolBGP, "192.0.2.1", ""},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("got %v", got)
+	}
+	if routes[4].RawProtocol != "O IA" || routes[4].Preference != 110 || routes[4].Metric != 3 {
+		t.Fatalf("got %+v", routes[4])
+	}
+	if route := LongestMatch(routes, "10.1.1.20"); route == nil || route.Prefix != "10.1.1.0/24" {
+		t.Fatalf("longest match got %+v", route)
+	}
+
+	//O*E2默认路由，以及网段与下一跳分两行显示的路由
+	routes, err = BuildRoutes(CISCO, "", fixtureRecords(t, CISCO, RoutesTemplate, "wrapped"))
+	if err != nil {
+		t.Fatalf("BuildRoutes err:%v", err)
+	}
+	if len(routes) != 5 || routes[0].Prefix != "0.0.0.0/0" || routes[0].Protocol != RouteProtocolOSPF || routes[0].RawProtocol != "O*E2" {
+		t.Fatalf("routes got %+v", routes)
+	}
+	if wrapped := routes[3]; wrapped.Prefix != "192.168.100.0/24" || wrapped.RawProtocol != "O E2" || len(wrapped.NextHops) != 2 || wrapped.Metric != 20 {
+		t.Fatalf("wrapped route got %+v", wrapped)
+	}
+	if routes[2].Prefix != "10.1.1.1/32" || len(routes[2].NextHops) != 1 || routes[4].Prefix != "192.168.200.0/24" {
+		t.Fatalf("routes got %+v", routes)
+	}
+	if route := LongestMatch(routes, "8.8.8.8"); route == nil || route.Prefix != "0.0.0.0/0" {
+		t.Fatalf("default route got %+v", route)
+	}
+}
+
+func TestVRFCmd(t *testing.T) {
+	cases := []struct {
+		brand, cmd, vrf, want string
+	}{
+		{HUAWEI, "display ip routing-table", "vpn1", "display ip routing-table vpn-instance vpn1"},
+		{H3C, "display ip routing-table", "", "display ip routing-table"},
+		{CISCO, "show ip route", "MGMT", "show ip route vrf MGMT"},
+		{JUNIPER, "show route", "MGMT", "show route"},
+	}
+	for _, c := range cases {
+		driver, _ := GetDriver(c.brand)
+		cmd := driver.VRFCmd(c.cmd, c.vrf)
+		if cmd != c.want {
+			t.Fatalf("%s got %s", c.brand, cmd)
+		}
+		if template, found := LookupTemplate(c.brand, cmd); c.brand != JUNIPER && (!found || template != RoutesTemplate) {
+			t.Fatalf("%s %s template got %s", c.brand, cmd, template)
+		}
+	}
+}
@@ -2,7 +2,6 @@ package arkssh
 
 import (
 	"errors"
-	"regexp"
 	"strings"
 	"time"
 
@@ -14,11 +13,17 @@ import (
  * @attr   session:原生的ssh session，in:绑定了session标准输入的管道，out:绑定了session标准输出的管道，lastUseTime:最后的使用时间
  */
 type SSHSession struct {
-	session     *ssh.Session
-	in          chan string
-	out         chan string
-	brand       string
-	lastUseTime time.Time
+	session       *ssh.Session
+	in            chan string
+	out           chan string
+	brand         string
+	versionOutput string //识别品牌时采集到的版本信息，获取设备基础信息时复用
+	serverVersion string //SSH服务端版本，如SSH-2.0-HUAWEI-1.5
+	banner        string //登录banner
+	loginOutput   string //登录后的回显，最后一行为提示符
+	detectResult  DetectResult
+	facts         *Facts
+	lastUseTime   time.Time
 }
 
 /**
@@ -71,12 +76,23 @@ func (s *SSHSession) createConnection(user, password, ipPort string) error {
 	// 创建一个20秒的定时器
 	timer := time.After(20 * time.Second)
 
-	// 在goroutine中执行client.Dial()函数
-	resultChan := make(chan *ssh.Session, 1)
+	// 在goroutine中执行client.Dial()函数，服务端版本和banner随session一起返回，超时后goroutine不再修改SSHSession
+	type dialResult struct {
+		session       *ssh.Session
+		serverVersion string
+		banner        string
+	}
+	resultChan := make(chan dialResult, 1)
 	errChan := make(chan error, 1)
 	go func() {
+		banner := ""
 		client, err := ssh.Dial("tcp", ipPort, &ssh.ClientConfig{
 			User: user,
+			//记录登录banner，用于识别品牌
+			BannerCallback: func(message string) error {
+				banner += message
+				return nil
+			},
 			Auth: []ssh.AuthMethod{
 				ssh.Password(password),
 			},
@@ -117,13 +133,13 @@ func (s *SSHSession) createConnection(user, password, ipPort string) error {
 			errChan <- err
 			return
 		}
-		resultChan <- session
+		resultChan <- dialResult{session: session, serverVersion: string(client.ServerVersion()), banner: banner}
 	}()
 
 	// 等待client.Dial()函数执行完成或定时器超时
 	select {
-	case session := <-resultChan:
-		s.session = session
+	case result := <-resultChan:
+		s.session, s.serverVersion, s.banner = result.session, result.serverVersion, result.banner
 		LogDebug("<Test> End new session")
 		return nil
 	case err := <-errChan:
@@ -217,8 +233,8 @@ func (s *SSHSession) start() error {
 		LogError("Start shell error:%s", err.Error())
 		return err
 	}
-	//等待登录信息输出
-	s.ReadChannelExpect(time.Second, "#", ">", "]")
+	//等待登录信息输出，保留下来用于识别品牌
+	s.loginOutput = s.ReadChannelExpect(time.Second, "#", ">", "]")
 	return nil
 }
 
@@ -245,7 +261,7 @@ func (s *SSHSession) CheckSelf() bool {
 }
 
 /**
- * 获取当前SSH到的交换机的品牌
+ * 获取当前SSH到的交换机的品牌，识别的可信度可通过DetectBrand获取
  * @return string （huawei,h3c,cisco）
  */
 func (s *SSHSession) GetSSHBrand() string {
@@ -257,39 +273,8 @@ func (s *SSHSession) GetSSHBrand() string {
 	if s.brand != "" {
 		return s.brand
 	}
-	//显示版本后需要多一组空格，避免版本信息过多需要分页，导致分页指令第一个字符失效的问题
-	s.WriteChannel("dis version", "     ",
-		"show version", "     ",
-		"list mode", "     ",
-		"show privilege", "     ",
-		"uname -s", "     ")
-	result, _ := s.ReadChannelTiming(15)
-	result = strings.ToLower(result)
-	if strings.Contains(result, HUAWEI) || strings.Contains(result, HUARONG) || strings.Contains(result, FutureMatrix) {
-		LogDebug("The switch brand is <huawei>.")
-		s.brand = HUAWEI
-	} else if strings.Contains(result, H3C) {
-		LogDebug("The switch brand is <h3c>.")
-		s.brand = H3C
-	} else if strings.Contains(result, SANGFOR) {
-		LogDebug("The switch brand is <sangfor>.")
-		s.brand = SANGFOR
-	} else if strings.Contains(result, AnShi) || strings.Contains(result, "fit mode") || strings.Contains(result, "fat mode") {
-		LogDebug("The switch brand is <anshi>.")
-		s.brand = AnShi
-	} else if strings.Contains(result, LINUX) {
-		LogDebug("The device brand is <linux>.")
-		s.brand = LINUX
-	} else if strings.Contains(result, DIPU) {
-		LogDebug("The switch vendor is <dipu>.")
-		s.brand = DIPU
-	} else if strings.Contains(result, "current privilege") {
-		LogDebug("The switch vendor is <zte>.")
-		s.brand = ZTE
-	} else if strings.Contains(result, CISCO) {
-		LogDebug("The switch brand is <cisco>.")
-		s.brand = CISCO
-	}
+	//优先通过SSH服务端版本、banner和提示符识别，有歧义时才逐条发送探测命令
+	s.DetectBrand()
 	return s.brand
 }
 
@@ -302,8 +287,11 @@ func (s *SSHSession) Close() {
 			LogError("SSHSession Close err:%s", err)
 		}
 	}()
-	if err := s.session.Close(); err != nil {
-		LogError("Close session err:%s", err.Error())
+	//未建立ssh连接的会话（如测试中的会话）只关闭管道
+	if s.session != nil {
+		if err := s.session.Close(); err != nil {
+			LogError("Close session err:%s", err.Error())
+		}
 	}
 	close(s.in)
 	close(s.out)
@@ -364,8 +352,8 @@ func (s *SSHSession) ReadChannelExpect(timeout time.Duration, expects ...string)
  */
 func (s *SSHSession) ReadChannelTiming(timeout int) (string, bool) {
 	output := ""
-	// 编译正则表达式，匹配所有可能的提示符
-	reg := regexp.MustCompile(PROMPT)
+	// 获取当前品牌的提示符正则，品牌未知时匹配所有可能的提示符
+	reg := promptRegexp(s.brand)
 	//设定每次从管道取值的间隔（微秒）
 	loopDelay := 100
 	loops := timeout * 1000 / loopDelay
@@ -5,6 +5,7 @@ import (
 	"time"
 )
 
+// 各品牌禁止分页的命令，内置Driver在建立会话时读取，运行时修改同样生效
 var (
 	HuaweiNoPage  = "screen-length 0 temporary"
 	H3cNoPage     = "screen-length disable"
@@ -14,10 +15,24 @@ var (
 	ZTENoPage     = "terminal length 0"
 	AnshiNoPage   = ""
 	LinuxNopage   = ""
+	JuniperNoPage = "set cli screen-length 0"
+	AristaNoPage  = "terminal length 0"
+	RuijieNoPage  = "terminal length 0"
+	// fortinet没有只对当前会话生效的禁止分页命令，默认不发送，超过一屏的回显会停在--More--；需要时设置为FortinetConsoleNoPage
+	FortinetNoPage  = ""
+	PaloAltoNoPage  = "set cli pager off"
+	HillstoneNoPage = "terminal length 0"
 )
 
+// fortinet通过console配置禁止分页，会永久修改防火墙的console配置（output standard）且不会恢复，只在调用方明确需要时使用：FortinetNoPage = FortinetConsoleNoPage。
+// 开启VDOM时console配置在global下，未开启VDOM时config global会报错但不影响后续命令
+const FortinetConsoleNoPage = "config global\nconfig system console\nset output standard\nend\nend"
+
 var sessionManager = NewSessionManager()
 
+// 建立新会话的方法，测试时替换为不需要真实设备的会话
+var newSSHSession = NewSSHSession
+
 /**
  * 创建一个SessionManager，相当于SessionManager的构造函数
  * @return SessionManager实例
@@ -61,6 +76,20 @@ func (s *SessionManager) GetSessionCache(sessionKey string) *SSHSession {
 	}
 }
 
+/**
+ * 关闭并移除缓存中的session，用于只需使用一次的会话（如拓扑采集），避免等待自动清理
+ * @param  sessionKey:session的索引键值
+ */
+func (s *SessionManager) removeSession(sessionKey string) {
+	s.sessionCacheLocker.Lock()
+	session, ok := s.sessionCache[sessionKey]
+	delete(s.sessionCache, sessionKey)
+	s.sessionCacheLocker.Unlock()
+	if ok {
+		session.Close()
+	}
+}
+
 /**
  * 给指定的session上锁
  * @param  sessionKey:session的索引键值
@@ -96,7 +125,7 @@ func (s *SessionManager) UnlockSession(sessionKey string) {
  */
 func (s *SessionManager) updateSession(user, password, ipPort, brand string) error {
 	sessionKey := user + "_" + password + "_" + ipPort
-	mySession, err := NewSSHSession(user, password, ipPort)
+	mySession, err := newSSHSession(user, password, ipPort)
 	if err != nil {
 		LogDebug("NewSSHSession err:%s", err.Error())
 		return err
@@ -117,32 +146,15 @@ func (s *SessionManager) initSession(session *SSHSession, brand string) {
 		LogDebug("brand不存在，自动获取brand中")
 		//如果传入的设备型号不匹配则自己获取
 		brand = session.GetSSHBrand()
+	} else {
+		session.brand = brand
 	}
-	switch brand {
-	case HUAWEI:
-		session.WriteChannel(HuaweiNoPage)
-		break
-	case H3C:
-		session.WriteChannel(H3cNoPage)
-		break
-	case SANGFOR:
-		session.WriteChannel(SangforNoPage)
-		break
-	case AnShi:
-		session.WriteChannel(AnshiNoPage)
-		break
-	case CISCO:
-		session.WriteChannel(CiscoNoPage)
-		break
-	case LINUX:
-		session.WriteChannel(LinuxNopage)
-		break
-	case ZTE:
-		session.WriteChannel(ZTENoPage)
-		break
-	case "":
+	if driver, ok := GetDriver(brand); ok {
+		session.WriteChannel(driver.NoPageCmds()...)
+	} else if brand == "" {
+		//仍然识别不到品牌，则把常见的禁止分页命令都发一遍
 		session.WriteChannel(HuaweiNoPage, H3cNoPage, SangforNoPage, DiPuNoPage)
-	default:
+	} else {
 		return
 	}
 	session.ReadChannelTiming(5)
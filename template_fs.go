@@ -0,0 +1,101 @@
+package arkssh
+
+import (
+	"embed"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path"
+	"sync"
+)
+
+// 随包发布的textfsm模板，目录结构为common/textfsm/<brand>/<name>
+//
+//go:embed common/textfsm
+var bundledTemplates embed.FS
+
+// ErrTemplateNotFound 品牌下找不到指定的textfsm模板
+var ErrTemplateNotFound = errors.New("textfsm模板不存在")
+
+var (
+	templateOverrideFS fs.FS
+	templateFSLocker   = new(sync.RWMutex)
+)
+
+/**
+ * 设置用户的模板目录，目录结构与common/textfsm相同（<dir>/<brand>/<name>），同名模板优先于内置模板
+ * @param	dir，模板目录，为空表示取消覆盖，只使用内置模板
+ */
+func SetTemplateOverrideDir(dir string) {
+	if dir == "" {
+		SetTemplateOverrideFS(nil)
+		return
+	}
+	SetTemplateOverrideFS(os.DirFS(dir))
+}
+
+/**
+ * 设置用户的模板文件系统（如调用方自己通过go:embed打包的模板），同名模板优先于内置模板
+ * @param	fsys，模板文件系统，根目录下为各品牌的目录，为nil表示取消覆盖
+ */
+func SetTemplateOverrideFS(fsys fs.FS) {
+	templateFSLocker.Lock()
+	templateOverrideFS = fsys
+	templateFSLocker.Unlock()
+	//覆盖的模板变化后，之前编译的模板缓存需要失效
+	ResetTextFsmCache()
+}
+
+/**
+ * 获取模板查找使用的文件系统：用户覆盖的模板在上层，内置模板在下层
+ * @return  fs.FS，根目录下为各品牌的目录
+ */
+func TemplateFS() fs.FS {
+	templateFSLocker.RLock()
+	defer templateFSLocker.RUnlock()
+	bundled, _ := fs.Sub(bundledTemplates, "common/textfsm")
+	if templateOverrideFS == nil {
+		return bundled
+	}
+	return layeredFS{templateOverrideFS, bundled}
+}
+
+/**
+ * 读取某个品牌的textfsm模板内容
+ * @param	brand，品牌名称，templateName，模板名称
+ * @return  模板内容，找不到模板时返回的错误包含ErrTemplateNotFound以及品牌和模板名称
+ */
+func ReadTemplate(brand, templateName string) (string, error) {
+	if brand == "" {
+		return "", errors.New("brand为空，无法读取textfsm模板")
+	}
+	name := path.Join(brand, templateName)
+	if !fs.ValidPath(name) {
+		return "", fmt.Errorf("textfsm模板名称不合法,brand:%s,template:%s", brand, templateName)
+	}
+	content, err := fs.ReadFile(TemplateFS(), name)
+	if errors.Is(err, fs.ErrNotExist) {
+		return "", fmt.Errorf("%w,brand:%s,template:%s", ErrTemplateNotFound, brand, templateName)
+	}
+	if err != nil {
+		return "", fmt.Errorf("读取textfsm模板失败,brand:%s,template:%s,err:%v", brand, templateName, err)
+	}
+	return string(content), nil
+}
+
+// 按顺序在多层文件系统中查找文件，上层存在同名文件时屏蔽下层
+type layeredFS []fs.FS
+
+func (l layeredFS) Open(name string) (fs.File, error) {
+	for _, layer := range l {
+		file, err := layer.Open(name)
+		if err == nil {
+			return file, nil
+		}
+		if !errors.Is(err, fs.ErrNotExist) {
+			return nil, err
+		}
+	}
+	return nil, &fs.PathError{Op: "open", Path: name, Err: fs.ErrNotExist}
+}
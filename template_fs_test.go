@@ -0,0 +1,28 @@
+package arkssh
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"testing/fstest"
+)
+
+func TestReadTemplate(t *testing.T) {
+	if _, err := ReadTemplate(HUAWEI, "hostname"); err != nil {
+		t.Fatalf("bundled template should be readable, err:%v", err)
+	}
+	_, err := ReadTemplate(CISCO, "not_exist")
+	if !errors.Is(err, ErrTemplateNotFound) || !strings.Contains(err.Error(), "cisco") || !strings.Contains(err.Error(), "not_exist") {
+		t.Fatalf("missing template error should name brand and template, got:%v", err)
+	}
+
+	SetTemplateOverrideFS(fstest.MapFS{"huawei/hostname": {Data: []byte("Value hostname (\\S+)\n\nStart\n ^\\[${hostname}\\]\n")}})
+	defer SetTemplateOverrideFS(nil)
+	content, err := ReadTemplate(HUAWEI, "hostname")
+	if err != nil || !strings.Contains(content, `^\[`) {
+		t.Fatalf("override template should shadow bundled one, got:%q err:%v", content, err)
+	}
+	if _, err := ReadTemplate(HUAWEI, "mac_tables"); err != nil {
+		t.Fatalf("bundled template should still be readable under override, err:%v", err)
+	}
+}
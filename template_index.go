@@ -0,0 +1,112 @@
+package arkssh
+
+import (
+	"errors"
+	"regexp"
+	"strings"
+)
+
+// 每个品牌目录下命令与模板对应关系的索引文件名称
+const TemplateIndexFile = "index"
+
+/**
+ * 索引文件中的一行，命令匹配时使用对应的模板
+ * @attr Template:模板名称，Command:命令的正则（已展开缩写）
+ */
+type templateIndexEntry struct {
+	Template string
+	Command  *regexp.Regexp
+}
+
+var (
+	templateIndexCache = make(map[string][]templateIndexEntry)
+	// ntc-templates风格的缩写写法，如dis[[play]]表示dis、disp、displ、displa、display均可
+	abbreviationRegexp = regexp.MustCompile(`\[\[([^\]]+)\]\]`)
+)
+
+/**
+ * 根据品牌和命令查找对应的textfsm模板，索引文件为common/textfsm/<brand>/index，格式与ntc-templates的index相同：
+ *   Template, Command
+ *   intf_up_downs, dis[[play]] int[[erface]]
+ * 命令按顺序匹配，第一个匹配的模板生效
+ * @param	brand，品牌名称，cmd，执行的命令
+ * @return  模板名称，bool为false表示没有对应的模板
+ */
+func LookupTemplate(brand, cmd string) (string, bool) {
+	entries, err := templateIndex(brand)
+	if err != nil {
+		LogDebug("读取模板索引失败:%s", err.Error())
+		return "", false
+	}
+	cmd = strings.Join(strings.Fields(cmd), " ")
+	for _, entry := range entries {
+		if entry.Command.MatchString(cmd) {
+			return entry.Template, true
+		}
+	}
+	return "", false
+}
+
+/**
+ * 将ntc-templates风格的命令缩写展开为正则，如sh[[ow]] ver[[sion]] -> sh(o(w)?)? ver(s(i(o(n)?)?)?)?
+ * @param	command，索引中的命令
+ * @return  展开后的正则（未加锚点）
+ */
+func ExpandCommandAbbreviation(command string) string {
+	return abbreviationRegexp.ReplaceAllStringFunc(command, func(match string) string {
+		optional := abbreviationRegexp.FindStringSubmatch(match)[1]
+		expanded := ""
+		for i := len(optional) - 1; i >= 0; i-- {
+			expanded = "(" + regexp.QuoteMeta(string(optional[i])) + expanded + ")?"
+		}
+		return expanded
+	})
+}
+
+// 读取并缓存品牌的模板索引，模板缓存清空时一并清空
+func templateIndex(brand string) ([]templateIndexEntry, error) {
+	textFsmCacheLocker.RLock()
+	entries, ok := templateIndexCache[brand]
+	textFsmCacheLocker.RUnlock()
+	if ok {
+		return entries, nil
+	}
+	content, err := ReadTemplate(brand, TemplateIndexFile)
+	if err != nil && !errors.Is(err, ErrTemplateNotFound) {
+		return nil, err
+	}
+	entries, err = parseTemplateIndex(content)
+	if err != nil {
+		return nil, err
+	}
+	textFsmCacheLocker.Lock()
+	templateIndexCache[brand] = entries
+	textFsmCacheLocker.Unlock()
+	return entries, nil
+}
+
+// 解析索引文件，#开头的行为注释，第一行非注释内容为表头
+func parseTemplateIndex(content string) ([]templateIndexEntry, error) {
+	entries := make([]templateIndexEntry, 0)
+	headerSkipped := false
+	for _, line := range strings.Split(content, "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		if !headerSkipped {
+			headerSkipped = true
+			continue
+		}
+		fields := strings.SplitN(line, ",", 2)
+		if len(fields) != 2 {
+			return nil, errors.New("模板索引格式错误:" + line)
+		}
+		re, err := regexp.Compile("^" + ExpandCommandAbbreviation(strings.TrimSpace(fields[1])) + `\s*$`)
+		if err != nil {
+			return nil, errors.New("模板索引命令正则错误:" + line)
+		}
+		entries = append(entries, templateIndexEntry{Template: strings.TrimSpace(fields[0]), Command: re})
+	}
+	return entries, nil
+}
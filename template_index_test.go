@@ -0,0 +1,45 @@
+package arkssh
+
+import "testing"
+
+func TestLookupTemplate(t *testing.T) {
+	cases := []struct {
+		brand, cmd, want string
+	}{
+		{HUAWEI, "dis int", "intf_up_downs"},
+		{HUAWEI, "display interface", "intf_up_downs"},
+		{HUAWEI, "disp  mac-address", "mac_tables"},
+		{HUAWEI, "display current-configuration interface", "intf_infos"},
+		{H3C, "dis ver", "facts"},
+		{CISCO, "sh ver", "facts"},
+		{JUNIPER, "show interfaces terse", "intf_up_downs"},
+		{CISCO, "show mac address-table", "mac_tables"},
+		{CISCO, "sh run int", "intf_infos"},
+		{ZTE, "show interface brief", "intf_up_downs"},
+		{DIPU, "show mac-address", "mac_tables"},
+	}
+	for _, c := range cases {
+		got, ok := LookupTemplate(c.brand, c.cmd)
+		if !ok || got != c.want {
+			t.Errorf("LookupTemplate(%s, %q)=%q, want %q", c.brand, c.cmd, got, c.want)
+		}
+	}
+	for _, cmd := range []string{"display interface brief", "dis clock", "d int"} {
+		if got, ok := LookupTemplate(HUAWEI, cmd); ok {
+			t.Errorf("LookupTemplate(huawei, %q)=%q, want none", cmd, got)
+		}
+	}
+	if _, ok := LookupTemplate(LINUX, "uname -a"); ok {
+		t.Errorf("brand without index should not match")
+	}
+}
+
+func TestParseCmdResult(t *testing.T) {
+	d := Device{Brand: HUAWEI, AutoTextFsm: true}
+	one := OneCMDRes{RES: "dis int\r\nGigabitEthernet1/0/1 current state : UP\r\nLine protocol current state : UP\r\n" +
+		"GigabitEthernet1/0/2 current state : Administratively DOWN\r\nLine protocol current state : DOWN\r\n<SW-01>"}
+	d.parseCmdResult("dis int", &one)
+	if one.Template != "intf_up_downs" || len(one.TextFsmResults) != 2 {
+		t.Fatalf("parseCmdResult got template %q results %v", one.Template, one.TextFsmResults)
+	}
+}
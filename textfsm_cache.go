@@ -0,0 +1,119 @@
+package arkssh
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"io/fs"
+	"path"
+	"sync"
+	"time"
+
+	"github.com/sirikothe/gotextfsm"
+)
+
+// 内联模板（TextFsmContent）最多缓存的数量，超过后清空重新缓存，避免调用方动态生成模板导致内存无限增长
+const maxContentTemplateCache = 1024
+
+/**
+ * 编译后的textfsm模板缓存项
+ * @attr fsm:编译后的模板，modTime:模板文件的修改时间（内置模板为零值），用于发现覆盖目录中模板的变化
+ */
+type textFsmCacheItem struct {
+	fsm     gotextfsm.TextFSM
+	modTime time.Time
+}
+
+var (
+	templateCache        = make(map[string]*textFsmCacheItem)
+	contentTemplateCache = make(map[string]*textFsmCacheItem)
+	textFsmCacheLocker   = new(sync.RWMutex)
+)
+
+/**
+ * 清空编译后的textfsm模板缓存，切换覆盖目录时会自动调用
+ */
+func ResetTextFsmCache() {
+	textFsmCacheLocker.Lock()
+	defer textFsmCacheLocker.Unlock()
+	templateCache = make(map[string]*textFsmCacheItem)
+	contentTemplateCache = make(map[string]*textFsmCacheItem)
+	templateIndexCache = make(map[string][]templateIndexEntry)
+}
+
+/**
+ * 获取某个品牌编译后的模板，缓存以brand/template为键；模板文件的修改时间变化时重新编译
+ * @param	brand，品牌名称，templateName，模板名称
+ * @return  可直接用于解析的模板副本
+ */
+func compiledTemplate(brand, templateName string) (gotextfsm.TextFSM, error) {
+	key := path.Join(brand, templateName)
+	var modTime time.Time
+	if info, err := fs.Stat(TemplateFS(), key); err == nil {
+		modTime = info.ModTime()
+	}
+	textFsmCacheLocker.RLock()
+	item, ok := templateCache[key]
+	textFsmCacheLocker.RUnlock()
+	if ok && item.modTime.Equal(modTime) {
+		return cloneTextFSM(item.fsm), nil
+	}
+	content, err := ReadTemplate(brand, templateName)
+	if err != nil {
+		return gotextfsm.TextFSM{}, err
+	}
+	fsm := gotextfsm.TextFSM{}
+	if err := fsm.ParseString(content); err != nil {
+		return gotextfsm.TextFSM{}, err
+	}
+	textFsmCacheLocker.Lock()
+	templateCache[key] = &textFsmCacheItem{fsm: fsm, modTime: modTime}
+	textFsmCacheLocker.Unlock()
+	return cloneTextFSM(fsm), nil
+}
+
+/**
+ * 获取内联模板编译后的结果，缓存以模板内容的sha256为键
+ * @param	content，模板内容
+ * @return  可直接用于解析的模板副本
+ */
+func compiledContent(content string) (gotextfsm.TextFSM, error) {
+	sum := sha256.Sum256([]byte(content))
+	key := hex.EncodeToString(sum[:])
+	textFsmCacheLocker.RLock()
+	item, ok := contentTemplateCache[key]
+	textFsmCacheLocker.RUnlock()
+	if ok {
+		return cloneTextFSM(item.fsm), nil
+	}
+	fsm := gotextfsm.TextFSM{}
+	if err := fsm.ParseString(content); err != nil {
+		return gotextfsm.TextFSM{}, err
+	}
+	textFsmCacheLocker.Lock()
+	if len(contentTemplateCache) >= maxContentTemplateCache {
+		contentTemplateCache = make(map[string]*textFsmCacheItem)
+	}
+	contentTemplateCache[key] = &textFsmCacheItem{fsm: fsm}
+	textFsmCacheLocker.Unlock()
+	return cloneTextFSM(fsm), nil
+}
+
+/**
+ * 复制编译后的模板。解析过程中gotextfsm会把当前值写回Values，多个协程共用同一个模板会互相影响，
+ * 因此每次解析都使用一份全新的Values，States只读可以共用
+ * @param	fsm，编译后的模板
+ * @return  模板副本
+ */
+func cloneTextFSM(fsm gotextfsm.TextFSM) gotextfsm.TextFSM {
+	clone := fsm
+	clone.Values = make(map[string]gotextfsm.TextFSMValue, len(fsm.Values))
+	for name, value := range fsm.Values {
+		clone.Values[name] = gotextfsm.TextFSMValue{
+			Regex:    value.Regex,
+			Template: value.Template,
+			Name:     value.Name,
+			Options:  value.Options,
+		}
+	}
+	return clone
+}
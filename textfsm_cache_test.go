@@ -0,0 +1,35 @@
+package arkssh
+
+import (
+	"sync"
+	"testing"
+)
+
+func TestTextFsmCacheConcurrent(t *testing.T) {
+	output := "<SW-01>disp mac-address\n5489-98ac-0001 10/-/- GE1/0/1 dynamic\n5489-98ac-0002 20/-/- GE1/0/2 dynamic\n<SW-01>"
+	wg := sync.WaitGroup{}
+	for i := 0; i < 50; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			res, err := TextFsmParseViaTemplateFile(HUAWEI, output, "mac_tables")
+			if err != nil || len(res) != 2 {
+				t.Errorf("parse with cached template got %v, err:%v", res, err)
+			}
+		}()
+	}
+	wg.Wait()
+	if _, ok := templateCache["huawei/mac_tables"]; !ok {
+		t.Fatalf("compiled template should be cached")
+	}
+}
+
+func TestTextFsmCacheContent(t *testing.T) {
+	content := "Value Required hostname (\\S+)\n\nStart\n ^<${hostname}> -> Record\n"
+	for i := 0; i < 3; i++ {
+		res, err := TextFsmParseViaContent("<SW-01>\n<SW-02>", content)
+		if err != nil || len(res) != 2 || res[1]["hostname"] != "SW-02" {
+			t.Fatalf("parse with cached content got %v, err:%v", res, err)
+		}
+	}
+}
@@ -0,0 +1,240 @@
+package arkssh
+
+import (
+	"fmt"
+	"reflect"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// 结构体字段与textfsm Value名称对应的标签，如`textfsm:"port_name"`，`textfsm:"-"`表示忽略该字段
+const TextFsmTag = "textfsm"
+
+var (
+	durationType = reflect.TypeOf(time.Duration(0))
+	// 设备常见的运行时间写法，如1 year, 2 weeks, 3 days, 4 hours, 5 minutes或120 day(s), 3 hour(s)
+	uptimePartRegexp = regexp.MustCompile(`(\d+)\s*(year|week|day|hour|minute|min|second|sec)s?(\(s\))?`)
+	uptimeUnits      = map[string]time.Duration{
+		"year":   365 * 24 * time.Hour,
+		"week":   7 * 24 * time.Hour,
+		"day":    24 * time.Hour,
+		"hour":   time.Hour,
+		"minute": time.Minute,
+		"min":    time.Minute,
+		"second": time.Second,
+		"sec":    time.Second,
+	}
+)
+
+/**
+ * 将textfsm的解析结果转换为结构体切片，字段通过textfsm标签与Value名称对应，未写标签时按字段名不区分大小写匹配
+ * 支持的字段类型：string、int系列、uint系列、float系列、bool、time.Duration、[]string以及上述基础类型的切片
+ * @param	records，textfsm的解析结果（TextFsmParseViaTemplateFile等方法的返回值）
+ * @return  结构体切片；在所有记录中都没有被填充的字段名称；类型转换失败时的错误
+ */
+func ParseInto[T any](records []map[string]interface{}) ([]T, []string, error) {
+	var zero T
+	typ := reflect.TypeOf(zero)
+	if typ == nil || typ.Kind() != reflect.Struct {
+		return nil, nil, fmt.Errorf("ParseInto只支持结构体类型,实际为%v", typ)
+	}
+	fields := textFsmFields(typ)
+	filled := make([]bool, len(fields))
+	result := make([]T, 0, len(records))
+	for i, record := range records {
+		var item T
+		value := reflect.ValueOf(&item).Elem()
+		for j, field := range fields {
+			raw, ok := lookupRecordValue(record, field.name)
+			if !ok || isEmptyTextFsmValue(raw) {
+				continue
+			}
+			if err := setTextFsmField(value.Field(field.index), raw); err != nil {
+				return nil, nil, fmt.Errorf("第%d条记录的字段%s转换失败:%v", i, typ.Field(field.index).Name, err)
+			}
+			filled[j] = true
+		}
+		result = append(result, item)
+	}
+	unfilled := make([]string, 0)
+	for j, field := range fields {
+		if !filled[j] {
+			unfilled = append(unfilled, typ.Field(field.index).Name)
+		}
+	}
+	return result, unfilled, nil
+}
+
+/**
+ * 使用品牌的模板解析文本并转换为结构体切片
+ * @param	brand，品牌名称，waitToParse，待解析的文本，templateName，模板名称
+ * @return  同ParseInto
+ */
+func ParseTemplateInto[T any](brand, waitToParse, templateName string) ([]T, []string, error) {
+	records, err := TextFsmParseViaTemplateFile(brand, waitToParse, templateName)
+	if err != nil {
+		return nil, nil, err
+	}
+	return ParseInto[T](records)
+}
+
+/**
+ * 将设备回显中的运行时间转换为time.Duration，同时支持Go的时长写法（如1h30m）
+ * @param	text，运行时间，如1 year, 2 weeks, 3 days, 4 hours, 5 minutes
+ * @return  时长和转换错误
+ */
+func ParseUptime(text string) (time.Duration, error) {
+	text = strings.ToLower(strings.TrimSpace(text))
+	if d, err := time.ParseDuration(text); err == nil {
+		return d, nil
+	}
+	parts := uptimePartRegexp.FindAllStringSubmatch(text, -1)
+	if len(parts) == 0 {
+		return 0, fmt.Errorf("无法识别的时长:%s", text)
+	}
+	var total time.Duration
+	for _, part := range parts {
+		n, _ := strconv.Atoi(part[1])
+		total += time.Duration(n) * uptimeUnits[part[2]]
+	}
+	return total, nil
+}
+
+// 结构体字段与Value名称（小写）的对应关系
+type textFsmField struct {
+	name  string
+	index int
+}
+
+// 按字段顺序获取结构体字段与Value名称的对应关系
+func textFsmFields(typ reflect.Type) []textFsmField {
+	fields := make([]textFsmField, 0, typ.NumField())
+	for i := 0; i < typ.NumField(); i++ {
+		field := typ.Field(i)
+		if !field.IsExported() {
+			continue
+		}
+		name := field.Tag.Get(TextFsmTag)
+		if name == "-" {
+			continue
+		}
+		if name == "" {
+			name = field.Name
+		}
+		fields = append(fields, textFsmField{name: strings.ToLower(name), index: i})
+	}
+	return fields
+}
+
+// 不区分大小写地获取记录中的值
+func lookupRecordValue(record map[string]interface{}, name string) (interface{}, bool) {
+	if v, ok := record[name]; ok {
+		return v, true
+	}
+	for key, v := range record {
+		if strings.ToLower(key) == name {
+			return v, true
+		}
+	}
+	return nil, false
+}
+
+func isEmptyTextFsmValue(raw interface{}) bool {
+	switch v := raw.(type) {
+	case nil:
+		return true
+	case string:
+		return strings.TrimSpace(v) == ""
+	case []string:
+		return len(v) == 0
+	}
+	return false
+}
+
+// 将textfsm的值（string或[]string）写入结构体字段
+func setTextFsmField(field reflect.Value, raw interface{}) error {
+	if field.Kind() == reflect.Slice {
+		values, ok := raw.([]string)
+		if !ok {
+			str, isStr := raw.(string)
+			if !isStr {
+				return fmt.Errorf("不支持的值类型%T", raw)
+			}
+			values = []string{str}
+		}
+		slice := reflect.MakeSlice(field.Type(), 0, len(values))
+		for _, v := range values {
+			elem := reflect.New(field.Type().Elem()).Elem()
+			if err := setScalarField(elem, v); err != nil {
+				return err
+			}
+			slice = reflect.Append(slice, elem)
+		}
+		field.Set(slice)
+		return nil
+	}
+	switch v := raw.(type) {
+	case string:
+		return setScalarField(field, v)
+	case []string:
+		return setScalarField(field, strings.Join(v, " "))
+	default:
+		return fmt.Errorf("不支持的值类型%T", raw)
+	}
+}
+
+func setScalarField(field reflect.Value, raw string) error {
+	raw = strings.TrimSpace(raw)
+	if field.Type() == durationType {
+		d, err := ParseUptime(raw)
+		if err != nil {
+			return err
+		}
+		field.SetInt(int64(d))
+		return nil
+	}
+	switch field.Kind() {
+	case reflect.String:
+		field.SetString(raw)
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		n, err := strconv.ParseInt(raw, 10, field.Type().Bits())
+		if err != nil {
+			return err
+		}
+		field.SetInt(n)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		n, err := strconv.ParseUint(raw, 10, field.Type().Bits())
+		if err != nil {
+			return err
+		}
+		field.SetUint(n)
+	case reflect.Float32, reflect.Float64:
+		n, err := strconv.ParseFloat(raw, field.Type().Bits())
+		if err != nil {
+			return err
+		}
+		field.SetFloat(n)
+	case reflect.Bool:
+		b, err := parseTextFsmBool(raw)
+		if err != nil {
+			return err
+		}
+		field.SetBool(b)
+	default:
+		return fmt.Errorf("不支持的字段类型%s", field.Type())
+	}
+	return nil
+}
+
+// 除了true/false外，还支持设备回显中常见的yes/no、up/down、enable/disable等写法
+func parseTextFsmBool(raw string) (bool, error) {
+	switch strings.ToLower(raw) {
+	case "1", "t", "true", "y", "yes", "up", "on", "enable", "enabled":
+		return true, nil
+	case "0", "f", "false", "n", "no", "down", "off", "disable", "disabled":
+		return false, nil
+	}
+	return false, fmt.Errorf("无法转换为bool:%s", raw)
+}
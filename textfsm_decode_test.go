@@ -0,0 +1,43 @@
+package arkssh
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+type testIntfInfo struct {
+	PortName    string        `textfsm:"port_name"`
+	AccessVlan  int           `textfsm:"access_vlan"`
+	AllowTmp    []string      `textfsm:"allow_tmp"`
+	Up          bool          `textfsm:"status"`
+	Uptime      time.Duration `textfsm:"uptime"`
+	Description string
+	Ignored     string `textfsm:"-"`
+	NeverFilled string `textfsm:"not_in_template"`
+}
+
+func TestParseInto(t *testing.T) {
+	records := []map[string]interface{}{
+		{"port_name": "GE1/0/1", "access_vlan": "10", "allow_tmp": []string{}, "status": "up", "uptime": "1 week, 2 days, 3 hours", "description": "to-server"},
+		{"port_name": "Eth-Trunk1", "access_vlan": "", "allow_tmp": []string{"10 20", "30 to 40"}, "status": "DOWN", "uptime": "120 day(s), 3 hour(s)", "description": ""},
+	}
+	got, unfilled, err := ParseInto[testIntfInfo](records)
+	if err != nil {
+		t.Fatalf("ParseInto err:%v", err)
+	}
+	want := []testIntfInfo{
+		{PortName: "GE1/0/1", AccessVlan: 10, Up: true, Uptime: 9*24*time.Hour + 3*time.Hour, Description: "to-server"},
+		{PortName: "Eth-Trunk1", AllowTmp: []string{"10 20", "30 to 40"}, Uptime: 120*24*time.Hour + 3*time.Hour},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("ParseInto got %+v, want %+v", got, want)
+	}
+	if !reflect.DeepEqual(unfilled, []string{"NeverFilled"}) {
+		t.Fatalf("unfilled fields got %v", unfilled)
+	}
+
+	if _, _, err := ParseInto[testIntfInfo]([]map[string]interface{}{{"access_vlan": "abc"}}); err == nil {
+		t.Fatalf("invalid int should return error")
+	}
+}
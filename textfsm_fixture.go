@@ -0,0 +1,138 @@
+package arkssh
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"path"
+	"reflect"
+	"sort"
+	"strings"
+
+	"github.com/sirikothe/gotextfsm"
+)
+
+// 测试用例中设备原始回显和期望解析结果的文件后缀
+const (
+	FixtureRawExt      = ".raw"
+	FixtureExpectedExt = ".json"
+)
+
+/**
+ * textfsm模板的测试用例
+ * @attr Name:用例名称，Raw:设备原始回显，Expected:期望的解析结果
+ */
+type TextFsmFixture struct {
+	Name     string
+	Raw      string
+	Expected []map[string]interface{}
+}
+
+/**
+ * 从目录中加载测试用例，每个用例由同名的<name>.raw（设备原始回显）和<name>.json（期望的解析结果）组成
+ * @param	fsys，用例所在的目录，如os.DirFS("testdata/textfsm/huawei/mac_tables")
+ * @return  按名称排序的用例，缺少.json文件时返回错误
+ */
+func LoadTextFsmFixtures(fsys fs.FS) ([]TextFsmFixture, error) {
+	names, err := fs.Glob(fsys, "*"+FixtureRawExt)
+	if err != nil {
+		return nil, err
+	}
+	sort.Strings(names)
+	fixtures := make([]TextFsmFixture, 0, len(names))
+	for _, name := range names {
+		fixture := TextFsmFixture{Name: strings.TrimSuffix(name, FixtureRawExt)}
+		raw, err := fs.ReadFile(fsys, name)
+		if err != nil {
+			return nil, err
+		}
+		fixture.Raw = string(raw)
+		expected, err := fs.ReadFile(fsys, fixture.Name+FixtureExpectedExt)
+		if err != nil {
+			return nil, fmt.Errorf("测试用例%s缺少期望结果:%v", fixture.Name, err)
+		}
+		if err := json.Unmarshal(expected, &fixture.Expected); err != nil {
+			return nil, fmt.Errorf("测试用例%s的期望结果格式错误:%v", fixture.Name, err)
+		}
+		fixtures = append(fixtures, fixture)
+	}
+	return fixtures, nil
+}
+
+/**
+ * 校验textfsm模板：模板能够编译，并且每个用例的解析结果与期望一致，上线自定义的TextFsmContent前可调用
+ * @param	content，模板内容，fixtures，测试用例
+ * @return  所有不通过的用例汇总成的错误，全部通过时为nil
+ */
+func ValidateTextFsm(content string, fixtures ...TextFsmFixture) error {
+	if _, err := compiledContent(content); err != nil {
+		return fmt.Errorf("模板编译失败:%v", err)
+	}
+	errs := make([]error, 0)
+	for _, fixture := range fixtures {
+		got, err := ParseTextFsmFixture(content, fixture.Raw)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("用例%s解析失败:%v", fixture.Name, err))
+			continue
+		}
+		expected, _ := normalizeFixtureRecords(fixture.Expected)
+		if !reflect.DeepEqual(got, expected) {
+			gotJSON, _ := json.Marshal(got)
+			expectedJSON, _ := json.Marshal(expected)
+			errs = append(errs, fmt.Errorf("用例%s解析结果不一致\n期望:%s\n实际:%s", fixture.Name, expectedJSON, gotJSON))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+/**
+ * 使用模板解析用例的原始回显，结果经过json转换，便于与.json文件中的期望结果比较或生成期望结果
+ * 与TextFsmParseViaContent不同，解析结果为空时不返回错误
+ * @param	content，模板内容，raw，设备原始回显
+ * @return  解析结果
+ */
+func ParseTextFsmFixture(content, raw string) ([]map[string]interface{}, error) {
+	fsm, err := compiledContent(content)
+	if err != nil {
+		return nil, err
+	}
+	parser := gotextfsm.ParserOutput{}
+	if err := parser.ParseTextString(raw, fsm, true); err != nil {
+		return nil, err
+	}
+	return normalizeFixtureRecords(parser.Dict)
+}
+
+/**
+ * 校验某个品牌目录下的模板与用例目录中的用例
+ * @param	brand，品牌名称，templateName，模板名称，fixtureFS，用例所在的目录
+ * @return  校验错误
+ */
+func ValidateTemplateFixtures(brand, templateName string, fixtureFS fs.FS) error {
+	content, err := ReadTemplate(brand, templateName)
+	if err != nil {
+		return err
+	}
+	fixtures, err := LoadTextFsmFixtures(fixtureFS)
+	if err != nil {
+		return err
+	}
+	if len(fixtures) == 0 {
+		return fmt.Errorf("模板%s缺少测试用例", path.Join(brand, templateName))
+	}
+	return ValidateTextFsm(content, fixtures...)
+}
+
+// 通过json转换统一解析结果的类型（[]string和[]interface{}等）
+func normalizeFixtureRecords(records []map[string]interface{}) ([]map[string]interface{}, error) {
+	data, err := json.Marshal(records)
+	if err != nil {
+		return nil, err
+	}
+	normalized := make([]map[string]interface{}, 0)
+	if err := json.Unmarshal(data, &normalized); err != nil {
+		return nil, err
+	}
+	return normalized, nil
+}
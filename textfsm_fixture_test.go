@@ -0,0 +1,76 @@
+package arkssh
+
+import (
+	"encoding/json"
+	"flag"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// go test -run TestBundledTemplates -update 根据.raw重新生成.json，生成后需人工核对
+var updateFixtures = flag.Bool("update", false, "regenerate textfsm fixture expectations")
+
+const fixtureRoot = "testdata/textfsm"
+
+func TestBundledTemplates(t *testing.T) {
+	templates, err := fs.Glob(TemplateFS(), "*/*")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, name := range templates {
+		brand, templateName := filepath.Split(name)
+		brand = strings.TrimSuffix(brand, "/")
+		if templateName == TemplateIndexFile {
+			continue
+		}
+		t.Run(name, func(t *testing.T) {
+			dir := filepath.Join(fixtureRoot, brand, templateName)
+			if *updateFixtures {
+				updateTemplateFixtures(t, brand, templateName, dir)
+			}
+			if err := ValidateTemplateFixtures(brand, templateName, os.DirFS(dir)); err != nil {
+				t.Error(err)
+			}
+		})
+	}
+}
+
+func updateTemplateFixtures(t *testing.T, brand, templateName, dir string) {
+	content, err := ReadTemplate(brand, templateName)
+	if err != nil {
+		t.Fatal(err)
+	}
+	raws, _ := filepath.Glob(filepath.Join(dir, "*"+FixtureRawExt))
+	for _, raw := range raws {
+		data, err := os.ReadFile(raw)
+		if err != nil {
+			t.Fatal(err)
+		}
+		records, err := ParseTextFsmFixture(content, string(data))
+		if err != nil {
+			t.Fatal(err)
+		}
+		expected, _ := json.MarshalIndent(records, "", "  ")
+		if err := os.WriteFile(strings.TrimSuffix(raw, FixtureRawExt)+FixtureExpectedExt, append(expected, '\n'), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestValidateTextFsm(t *testing.T) {
+	content := "Value hostname (\\S+)\n\nStart\n ^<${hostname}>\n"
+	ok := TextFsmFixture{Name: "ok", Raw: "<SW-01>", Expected: []map[string]interface{}{{"hostname": "SW-01"}}}
+	if err := ValidateTextFsm(content, ok); err != nil {
+		t.Fatalf("ValidateTextFsm err:%v", err)
+	}
+	bad := TextFsmFixture{Name: "bad", Raw: "<SW-01>", Expected: []map[string]interface{}{{"hostname": "SW-02"}}}
+	if err := ValidateTextFsm(content, ok, bad); err == nil || !strings.Contains(err.Error(), "bad") {
+		t.Fatalf("mismatched fixture should be reported, got:%v", err)
+	}
+	if err := ValidateTextFsm("Value hostname \\S+\n\nStart\n ^${hostname}\n"); err == nil {
+		t.Fatalf("invalid template should be reported")
+	}
+}
@@ -0,0 +1,376 @@
+package arkssh
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+)
+
+/**
+ * 拓扑采集使用的登录凭据
+ * @attr Username:用户名，Password:密码，EnablePassword:提权密码，为空则不提权
+ */
+type Credential struct {
+	Username       string `bson:"username" json:"username"`
+	Password       string `bson:"password" json:"password"`
+	EnablePassword string `bson:"enable_password,omitempty" json:"enable_password,omitempty"`
+}
+
+// 拓扑采集默认同时登录的设备数量
+const defaultCrawlConcurrency = 20
+
+/**
+ * 拓扑采集的选项
+ * @attr MaxDepth:从种子设备开始最多采集的跳数，0表示只采集种子设备，Credentials:依次尝试的登录凭据（种子设备优先使用自带的用户名密码），
+ *       Timeout:读取单条命令回显的超时时间（秒），为0时使用默认值，Filter:返回false的邻居只记录节点和链路，不再登录采集（如服务器、AP），
+ *       Concurrency:同时登录采集的最大设备数，为0时使用默认值20
+ */
+type CrawlOptions struct {
+	MaxDepth    int
+	Credentials []Credential
+	Timeout     int
+	Filter      func(neighbor Neighbor) bool
+	Concurrency int
+}
+
+/**
+ * 拓扑中的节点
+ * @attr ID:节点标识（主机名，未知时为管理地址或机框MAC），Hostname:主机名，IP:管理地址，Brand:品牌，Depth:距种子设备的跳数，
+ *       Crawled:是否登录采集成功，Error:登录采集失败的原因
+ */
+type TopologyNode struct {
+	ID       string `bson:"id" json:"id"`
+	Hostname string `bson:"hostname,omitempty" json:"hostname,omitempty"`
+	IP       string `bson:"ip,omitempty" json:"ip,omitempty"`
+	Brand    string `bson:"brand,omitempty" json:"brand,omitempty"`
+	Depth    int    `bson:"depth" json:"depth"`
+	Crawled  bool   `bson:"crawled" json:"crawled"`
+	Error    string `bson:"error,omitempty" json:"error,omitempty"`
+}
+
+/**
+ * 拓扑中的链路，两端都采集到时只保留一条
+ * @attr Source:本端节点ID，SourcePort:本端端口，Target:对端节点ID，TargetPort:对端端口，Protocol:发现协议
+ */
+type TopologyLink struct {
+	Source     string `bson:"source" json:"source"`
+	SourcePort string `bson:"source_port,omitempty" json:"source_port,omitempty"`
+	Target     string `bson:"target" json:"target"`
+	TargetPort string `bson:"target_port,omitempty" json:"target_port,omitempty"`
+	Protocol   string `bson:"protocol" json:"protocol"`
+}
+
+/**
+ * 物理拓扑
+ * @attr Nodes:按发现顺序排列的节点，Links:链路
+ */
+type Topology struct {
+	Nodes []TopologyNode `bson:"nodes" json:"nodes"`
+	Links []TopologyLink `bson:"links" json:"links"`
+}
+
+// 待采集的设备，key为发现该设备时使用的节点key，种子设备为空
+type crawlTarget struct {
+	device Device
+	depth  int
+	key    string
+}
+
+// 单台设备的采集结果
+type crawlResult struct {
+	hostname  string
+	brand     string
+	neighbors []Neighbor
+	err       error
+}
+
+// 采集到的一条邻居关系，source为本端节点key
+type rawLink struct {
+	source   string
+	neighbor Neighbor
+}
+
+// 登录并采集单台设备的邻居，采集时新建的会话用完即关闭，不留在缓存中；可在测试中替换
+var crawlDevice = func(target Device, creds []Credential, timeout int) crawlResult {
+	result := crawlResult{err: errors.New("没有可用的登录凭据")}
+	for _, cred := range creds {
+		d := target
+		d.Username, d.Password, d.EnablePassword, d.Timeout = cred.Username, cred.Password, cred.EnablePassword, timeout
+		if d.Port == "" {
+			d.Port = "22"
+		}
+		sessionKey := d.Username + "_" + d.Password + "_" + d.IP + ":" + d.Port
+		cached := sessionManager.GetSessionCache(sessionKey) != nil
+		result = crawlResult{}
+		result.err = d.withSession(func(s *SSHSession) error {
+			var err error
+			result.hostname = PromptHostname(s.loginOutput)
+			result.brand = s.GetSSHBrand()
+			result.neighbors, err = s.GetNeighbors(d.getterTimeout())
+			return err
+		})
+		if !cached {
+			sessionManager.removeSession(sessionKey)
+		}
+		// 只有认证失败时才尝试下一组凭据
+		if result.err == nil || !strings.Contains(result.err.Error(), "unable to authenticate") {
+			return result
+		}
+	}
+	return result
+}
+
+/**
+ * 从种子设备开始按广度优先采集LLDP/CDP邻居，生成物理拓扑，同一层的设备并发采集，同时登录的设备数不超过opts.Concurrency
+ * @param	seeds，种子设备，opts，采集选项
+ * @return  拓扑；部分设备采集失败时返回汇总的错误，失败原因同时记录在节点的Error中，拓扑仍然返回
+ */
+func CrawlTopology(seeds []Device, opts CrawlOptions) (*Topology, error) {
+	b := newTopologyBuilder()
+	level := make([]crawlTarget, 0, len(seeds))
+	for _, seed := range seeds {
+		if b.queuedIP[seed.IP] {
+			continue
+		}
+		b.queuedIP[seed.IP] = true
+		level = append(level, crawlTarget{device: seed})
+	}
+	concurrency := opts.Concurrency
+	if concurrency <= 0 {
+		concurrency = defaultCrawlConcurrency
+	}
+	sem := make(chan struct{}, concurrency)
+	errs := make([]error, 0)
+	for len(level) > 0 {
+		results := make([]crawlResult, len(level))
+		wg := sync.WaitGroup{}
+		wg.Add(len(level))
+		for i := range level {
+			sem <- struct{}{}
+			go func(i int, target crawlTarget) {
+				defer func() {
+					<-sem
+					wg.Done()
+				}()
+				creds := opts.Credentials
+				if target.device.Username != "" {
+					own := Credential{Username: target.device.Username, Password: target.device.Password, EnablePassword: target.device.EnablePassword}
+					creds = append([]Credential{own}, opts.Credentials...)
+				}
+				results[i] = crawlDevice(target.device, creds, opts.Timeout)
+			}(i, level[i])
+		}
+		wg.Wait()
+		next := make([]crawlTarget, 0)
+		for i, target := range level {
+			if results[i].err != nil {
+				errs = append(errs, fmt.Errorf("IP:%s,err:%v", target.device.IP, results[i].err))
+			}
+			for _, neighbor := range b.addResult(target, results[i]) {
+				if target.depth >= opts.MaxDepth || neighbor.MgmtIP == "" || b.queuedIP[neighbor.MgmtIP] {
+					continue
+				}
+				key := neighborNodeKey(neighbor)
+				if b.crawledKey[key] || (opts.Filter != nil && !opts.Filter(neighbor)) {
+					continue
+				}
+				b.queuedIP[neighbor.MgmtIP] = true
+				b.crawledKey[key] = true
+				next = append(next, crawlTarget{device: Device{IP: neighbor.MgmtIP, Port: target.device.Port}, depth: target.depth + 1, key: key})
+			}
+		}
+		level = next
+	}
+	topology := b.build()
+	LogDebug("拓扑采集完成,节点数量:%d,链路数量:%d", len(topology.Nodes), len(topology.Links))
+	return topology, errors.Join(errs...)
+}
+
+// 拓扑的构建过程，节点key为小写的节点ID
+type topologyBuilder struct {
+	nodes      map[string]*TopologyNode
+	order      []string
+	aliases    map[string]string
+	links      []rawLink
+	queuedIP   map[string]bool
+	crawledKey map[string]bool
+}
+
+func newTopologyBuilder() *topologyBuilder {
+	return &topologyBuilder{
+		nodes:      make(map[string]*TopologyNode),
+		aliases:    make(map[string]string),
+		queuedIP:   make(map[string]bool),
+		crawledKey: make(map[string]bool),
+	}
+}
+
+// 邻居对应的节点ID，优先使用系统名称
+func neighborNodeID(neighbor Neighbor) string {
+	for _, id := range []string{neighbor.RemoteSystem, neighbor.MgmtIP, neighbor.ChassisID} {
+		if id != "" {
+			return id
+		}
+	}
+	return ""
+}
+
+func neighborNodeKey(neighbor Neighbor) string {
+	return strings.ToLower(neighborNodeID(neighbor))
+}
+
+// 记录一台设备的采集结果，返回该设备的邻居
+func (b *topologyBuilder) addResult(target crawlTarget, result crawlResult) []Neighbor {
+	id, key := result.hostname, strings.ToLower(result.hostname)
+	if id == "" {
+		// 登录失败时沿用邻居通告的名称，种子设备使用IP
+		if discovered, ok := b.nodes[target.key]; ok {
+			id, key = discovered.ID, target.key
+		} else {
+			id, key = target.device.IP, strings.ToLower(target.device.IP)
+		}
+	}
+	b.crawledKey[key] = true
+	// 登录后的主机名与邻居通告的系统名称不同（如CDP带域名）时，合并为同一个节点
+	if target.key != "" && target.key != key {
+		b.aliases[target.key] = key
+		if discovered, ok := b.nodes[target.key]; ok {
+			delete(b.nodes, target.key)
+			if _, ok := b.nodes[key]; !ok {
+				b.nodes[key] = discovered
+				b.order = append(b.order, key)
+			}
+		}
+	}
+	node := b.node(key, id, target.depth)
+	node.ID = id
+	if result.hostname != "" {
+		node.Hostname = result.hostname
+	}
+	node.IP = target.device.IP
+	node.Brand = result.brand
+	node.Crawled = result.err == nil
+	if result.err != nil {
+		node.Error = result.err.Error()
+	}
+	for _, neighbor := range result.neighbors {
+		neighborID := neighborNodeID(neighbor)
+		if neighborID == "" {
+			continue
+		}
+		neighborKey := b.resolve(strings.ToLower(neighborID))
+		peer := b.node(neighborKey, neighborID, target.depth+1)
+		if peer.Hostname == "" && !peer.Crawled {
+			peer.Hostname = neighbor.RemoteSystem
+		}
+		if peer.IP == "" {
+			peer.IP = neighbor.MgmtIP
+		}
+		b.links = append(b.links, rawLink{source: key, neighbor: neighbor})
+	}
+	return result.neighbors
+}
+
+// 获取或创建节点，跳数取最小值
+func (b *topologyBuilder) node(key, id string, depth int) *TopologyNode {
+	node, ok := b.nodes[key]
+	if !ok {
+		node = &TopologyNode{ID: id, Depth: depth}
+		b.nodes[key] = node
+		b.order = append(b.order, key)
+	}
+	if depth < node.Depth {
+		node.Depth = depth
+	}
+	return node
+}
+
+func (b *topologyBuilder) resolve(key string) string {
+	for i := 0; i < len(b.aliases); i++ {
+		alias, ok := b.aliases[key]
+		if !ok {
+			break
+		}
+		key = alias
+	}
+	return key
+}
+
+// 生成拓扑，对端端口按对端品牌规范化后合并两端都采集到的链路
+func (b *topologyBuilder) build() *Topology {
+	topology := &Topology{Nodes: make([]TopologyNode, 0, len(b.order)), Links: make([]TopologyLink, 0, len(b.links))}
+	added := make(map[string]bool)
+	for _, key := range b.order {
+		if node, ok := b.nodes[key]; ok && !added[key] {
+			added[key] = true
+			topology.Nodes = append(topology.Nodes, *node)
+		}
+	}
+	seen := make(map[string]bool)
+	for _, link := range b.links {
+		source := b.nodes[b.resolve(link.source)]
+		target := b.nodes[b.resolve(neighborNodeKey(link.neighbor))]
+		if source == nil || target == nil {
+			continue
+		}
+		item := TopologyLink{
+			Source:     source.ID,
+			SourcePort: link.neighbor.LocalPort,
+			Target:     target.ID,
+			TargetPort: CanonicalPortName(target.Brand, link.neighbor.RemotePort),
+			Protocol:   link.neighbor.Protocol,
+		}
+		ends := []string{strings.ToLower(item.Source + "|" + item.SourcePort), strings.ToLower(item.Target + "|" + item.TargetPort)}
+		sort.Strings(ends)
+		if linkKey := strings.Join(ends, "-"); !seen[linkKey] {
+			seen[linkKey] = true
+			topology.Links = append(topology.Links, item)
+		}
+	}
+	return topology
+}
+
+/**
+ * 导出为JSON
+ * @return  JSON内容和序列化错误
+ */
+func (t *Topology) JSON() ([]byte, error) {
+	return json.MarshalIndent(t, "", "  ")
+}
+
+/**
+ * 导出为Graphviz DOT格式，未登录采集的节点为虚线，采集失败的节点为红色，链路两端标注端口
+ * @return  DOT内容
+ */
+func (t *Topology) DOT() string {
+	var sb strings.Builder
+	sb.WriteString("graph topology {\n")
+	for _, node := range t.Nodes {
+		label := node.ID
+		if node.IP != "" && node.IP != node.ID {
+			label += `\n` + node.IP
+		}
+		attrs := []string{"label=" + dotQuote(label)}
+		switch {
+		case node.Error != "":
+			attrs = append(attrs, "color=red")
+		case !node.Crawled:
+			attrs = append(attrs, "style=dashed")
+		}
+		sb.WriteString(fmt.Sprintf("  %s [%s];\n", dotQuote(node.ID), strings.Join(attrs, ", ")))
+	}
+	for _, link := range t.Links {
+		sb.WriteString(fmt.Sprintf("  %s -- %s [taillabel=%s, headlabel=%s];\n",
+			dotQuote(link.Source), dotQuote(link.Target), dotQuote(link.SourcePort), dotQuote(link.TargetPort)))
+	}
+	sb.WriteString("}\n")
+	return sb.String()
+}
+
+// DOT中的字符串用双引号包围，label中的\n保留为换行
+func dotQuote(text string) string {
+	return `"` + strings.ReplaceAll(text, `"`, `\"`) + `"`
+}
@@ -0,0 +1,166 @@
+package arkssh
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func fakeCrawl(t *testing.T, results map[string]crawlResult, tried map[string][]Credential) func(Device, []Credential, int) crawlResult {
+	return func(target Device, creds []Credential, timeout int) crawlResult {
+		tried[target.IP] = creds
+		result, ok := results[target.IP]
+		if !ok {
+			t.Errorf("unexpected crawl of %s", target.IP)
+		}
+		return result
+	}
+}
+
+func TestCrawlTopology(t *testing.T) {
+	results := map[string]crawlResult{
+		"10.1.1.1": {hostname: "CE-01", brand: HUAWEI, neighbors: []Neighbor{
+			{LocalPort: "GigabitEthernet1/0/1", RemoteSystem: "CE-02", RemotePort: "GE1/0/2", MgmtIP: "10.1.1.2", Protocol: NeighborProtocolLLDP},
+			{LocalPort: "GigabitEthernet1/0/48", RemoteSystem: "server-01", RemotePort: "eth0", Protocol: NeighborProtocolLLDP},
+		}},
+		"10.1.1.2": {hostname: "CE-02", brand: HUAWEI, neighbors: []Neighbor{
+			{LocalPort: "GigabitEthernet1/0/2", RemoteSystem: "CE-01", RemotePort: "GE1/0/1", MgmtIP: "10.1.1.1", Protocol: NeighborProtocolLLDP},
+			{LocalPort: "GigabitEthernet1/0/3", RemoteSystem: "SW-03.example.com", RemotePort: "GigabitEthernet1/0/1", MgmtIP: "10.1.1.3", Protocol: NeighborProtocolCDP},
+		}},
+		"10.1.1.3": {hostname: "SW-03", brand: CISCO, neighbors: []Neighbor{
+			{LocalPort: "GigabitEthernet1/0/1", RemoteSystem: "CE-02", RemotePort: "GE1/0/3", MgmtIP: "10.1.1.2", Protocol: NeighborProtocolLLDP},
+		}},
+	}
+	tried := make(map[string][]Credential)
+	defer func(origin func(Device, []Credential, int) crawlResult) { crawlDevice = origin }(crawlDevice)
+	crawlDevice = fakeCrawl(t, results, tried)
+
+	seeds := []Device{{IP: "10.1.1.1", Username: "seed", Password: "pw"}}
+	creds := []Credential{{Username: "netops", Password: "pw"}}
+	topology, err := CrawlTopology(seeds, CrawlOptions{MaxDepth: 1, Credentials: creds})
+	if err != nil {
+		t.Fatalf("CrawlTopology err:%v", err)
+	}
+	if len(tried["10.1.1.1"]) != 2 || tried["10.1.1.1"][0].Username != "seed" || len(tried["10.1.1.2"]) != 1 {
+		t.Fatalf("credentials got %+v", tried)
+	}
+	if _, ok := tried["10.1.1.3"]; ok {
+		t.Fatalf("depth limit exceeded")
+	}
+	var ids []string
+	for _, node := range topology.Nodes {
+		ids = append(ids, node.ID)
+	}
+	if strings.Join(ids, ",") != "CE-01,CE-02,server-01,SW-03.example.com" || topology.Nodes[2].Crawled || topology.Nodes[1].Depth != 1 {
+		t.Fatalf("nodes got %+v", topology.Nodes)
+	}
+	//两端都采集到的链路只保留一条，对端端口按对端品牌规范化
+	want := TopologyLink{Source: "CE-01", SourcePort: "GigabitEthernet1/0/1", Target: "CE-02", TargetPort: "GigabitEthernet1/0/2", Protocol: NeighborProtocolLLDP}
+	if len(topology.Links) != 3 || topology.Links[0] != want {
+		t.Fatalf("links got %+v", topology.Links)
+	}
+
+	//CDP带域名的节点登录后合并为主机名
+	topology, _ = CrawlTopology(seeds, CrawlOptions{MaxDepth: 2, Credentials: creds})
+	if len(topology.Nodes) != 4 || topology.Nodes[3].ID != "SW-03" || !topology.Nodes[3].Crawled || topology.Nodes[3].Brand != CISCO {
+		t.Fatalf("nodes got %+v", topology.Nodes)
+	}
+	if len(topology.Links) != 3 || topology.Links[2].Target != "SW-03" {
+		t.Fatalf("links got %+v", topology.Links)
+	}
+
+	dot := topology.DOT()
+	for _, line := range []string{
+		`"server-01" [label="server-01", style=dashed];`,
+		`"CE-01" -- "CE-02" [taillabel="GigabitEthernet1/0/1", headlabel="GigabitEthernet1/0/2"];`,
+	} {
+		if !strings.Contains(dot, line) {
+			t.Fatalf("dot missing %s:\n%s", line, dot)
+		}
+	}
+	data, err := topology.JSON()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var decoded Topology
+	if err := json.Unmarshal(data, &decoded); err != nil || len(decoded.Links) != 3 {
+		t.Fatalf("json got %s", data)
+	}
+}
+
+func TestCrawlTopologyConcurrency(t *testing.T) {
+	neighbors := make([]Neighbor, 0)
+	for i := 2; i <= 11; i++ {
+		neighbors = append(neighbors, Neighbor{LocalPort: fmt.Sprintf("GE1/0/%d", i), RemoteSystem: fmt.Sprintf("SW-%02d", i),
+			MgmtIP: fmt.Sprintf("10.1.1.%d", i), Protocol: NeighborProtocolLLDP})
+	}
+	var (
+		lock               sync.Mutex
+		running, maxActive int
+	)
+	defer func(origin func(Device, []Credential, int) crawlResult) { crawlDevice = origin }(crawlDevice)
+	crawlDevice = func(target Device, creds []Credential, timeout int) crawlResult {
+		lock.Lock()
+		running++
+		if running > maxActive {
+			maxActive = running
+		}
+		lock.Unlock()
+		time.Sleep(20 * time.Millisecond)
+		lock.Lock()
+		running--
+		lock.Unlock()
+		if target.IP == "10.1.1.1" {
+			return crawlResult{hostname: "CORE-01", brand: HUAWEI, neighbors: neighbors}
+		}
+		return crawlResult{hostname: strings.Replace(target.IP, "10.1.1.", "SW-", 1), brand: HUAWEI}
+	}
+	topology, err := CrawlTopology([]Device{{IP: "10.1.1.1"}}, CrawlOptions{MaxDepth: 1, Concurrency: 3})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(topology.Nodes) != 11 || maxActive > 3 {
+		t.Fatalf("nodes %d, max concurrent crawls %d", len(topology.Nodes), maxActive)
+	}
+}
+
+func TestCrawlDeviceSessionCache(t *testing.T) {
+	//调用方已缓存的会话在采集后保留
+	d := Device{IP: "192.0.2.20", Username: "admin", Password: "pass"}
+	s, received := newScriptedSession(HUAWEI, func(string) string { return "\r\n<CE-20>" })
+	cacheScriptedSession(t, &d, s)
+	sessionKey := "admin_pass_192.0.2.20:22"
+	result := crawlDevice(Device{IP: d.IP}, []Credential{{Username: "admin", Password: "pass"}}, 1)
+	if result.err != nil || result.brand != HUAWEI || len(*received) == 0 {
+		t.Fatalf("crawl got %+v, sent %q", result, *received)
+	}
+	if sessionManager.GetSessionCache(sessionKey) != s {
+		t.Fatalf("cached session should be kept")
+	}
+	//采集时新建的会话用完即关闭并移除
+	defer func(origin func(string, string, string) (*SSHSession, error)) { newSSHSession = origin }(newSSHSession)
+	opened, _ := newScriptedSession(HUAWEI, func(string) string { return "\r\n<CE-21>" })
+	dials := 0
+	newSSHSession = func(user, password, ipPort string) (*SSHSession, error) {
+		dials++
+		return opened, nil
+	}
+	result = crawlDevice(Device{IP: "192.0.2.21"}, []Credential{{Username: "admin", Password: "pass"}}, 1)
+	if result.err != nil || dials != 1 {
+		t.Fatalf("crawl got %+v, dials %d", result, dials)
+	}
+	if sessionManager.GetSessionCache("admin_pass_192.0.2.21:22") != nil {
+		t.Fatalf("session opened by the crawl should be removed")
+	}
+	select {
+	case _, ok := <-opened.out:
+		if ok {
+			t.Fatalf("session opened by the crawl should be closed")
+		}
+	case <-time.After(time.Second):
+		t.Fatalf("session opened by the crawl should be closed")
+	}
+}
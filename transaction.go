@@ -0,0 +1,317 @@
+package arkssh
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// 事务推送的最终状态
+const (
+	TxnApplied    = "applied"     //配置已生效
+	TxnRolledBack = "rolled_back" //推送或检查失败，已恢复到推送前的状态
+	TxnUnknown    = "unknown"     //恢复失败或无法确认，需要人工检查
+	TxnNotApplied = "not_applied" //创建检查点或进入配置模式失败，未做任何修改
+)
+
+// 单条命令最多自动应答的确认次数
+const maxConfirmTimes = 3
+
+// 命令回显末尾的确认提示，如Continue? [Y/N]:、[Y(yes)/N(no)/C(cancel)]:、(y/n)[n]:、Destination filename [x.cfg]?、[confirm]，应答由Driver的TransactionCmds定义
+var confirmPrompts = []*regexp.Regexp{
+	regexp.MustCompile(`(?i)\[\s*yes/no[^\]]*\][:?]?\s*$`),
+	regexp.MustCompile(`(?i)\[\s*y(\(yes\))?/n[^\]]*\][:?]?\s*$`),
+	regexp.MustCompile(`(?i)\(y/n\)\s*\[[yn]\][:?]?\s*$`),
+	regexp.MustCompile(`\[[^\]\n]*\]\?\s*$`),
+	regexp.MustCompile(`\[confirm\]\s*$`),
+}
+
+/**
+ * 推送后的检查命令，回显中出现Driver的错误信息、不匹配Expect或匹配Reject时视为检查失败
+ * @attr Cmd:检查命令，Expect:回显必须匹配的正则，为空不检查，Reject:回显不能匹配的正则，为空不检查
+ */
+type PostCheck struct {
+	Cmd    string `bson:"cmd" json:"cmd"`
+	Expect string `bson:"expect,omitempty" json:"expect,omitempty"`
+	Reject string `bson:"reject,omitempty" json:"reject,omitempty"`
+}
+
+/**
+ * 事务推送的结果
+ * @attr Outcome:最终状态（applied/rolled_back/unknown/not_applied），FailedCheck:失败的检查命令，RollbackError:恢复失败的原因，
+ *       ArmedAt:确认推送时启动自动恢复定时器的时间，RevertAt:设备自动恢复的时间，超过后不能再确认
+ */
+type TransactionResult struct {
+	PushResult    `bson:",inline"`
+	Outcome       string    `bson:"outcome" json:"outcome"`
+	FailedCheck   string    `bson:"failed_check,omitempty" json:"failed_check,omitempty"`
+	RollbackError string    `bson:"rollback_error,omitempty" json:"rollback_error,omitempty"`
+	ArmedAt       time.Time `bson:"armed_at,omitempty" json:"armed_at,omitempty"`
+	RevertAt      time.Time `bson:"revert_at,omitempty" json:"revert_at,omitempty"`
+}
+
+/**
+ * 外部调用的统一方法，以事务方式推送配置，任一行失败或检查失败时恢复到推送前的状态
+ * @param	lines，配置行，checks，推送后的检查命令，save，检查通过后是否保存配置
+ * @return  推送结果（Outcome为最终状态）和执行错误
+ */
+func (d *Device) PushConfigTransaction(lines []string, checks []PostCheck, save bool) (*TransactionResult, error) {
+	result := &TransactionResult{PushResult: PushResult{IP: d.IP}, Outcome: TxnNotApplied}
+	err := d.withSession(func(s *SSHSession) error {
+		pushed, err := s.PushConfigTransaction(lines, checks, save, d.getterTimeout())
+		if pushed != nil {
+			pushed.IP = d.IP
+			result = pushed
+		}
+		return err
+	})
+	if err != nil {
+		LogError("事务推送配置错误:%s,IP:%s,状态:%s", err.Error(), d.IP, result.Outcome)
+		return result, err
+	}
+	LogDebug("事务推送配置成功,IP:%s,行数:%d,是否保存:%v", d.IP, len(result.Applied), result.Saved)
+	return result, nil
+}
+
+/**
+ * 在当前SSH到的设备上以事务方式推送配置，恢复方式由Driver的TransactionCmds定义：
+ * 支持两阶段提交的品牌（华为CE）在提交前失败时放弃候选配置，其余情况按Checkpoint创建的检查点回退（H3C的configuration replace、cisco的configure replace）；
+ * 定义了TwoStagePattern的品牌先进入配置模式查看提示符，一阶段生效的设备（华为S系列）改用OneStage的命令
+ * @param	lines，配置行，checks，推送后的检查命令，save，检查通过后是否保存配置，timeout，读取单行回显的超时时间（秒）
+ * @return  推送结果和执行错误，Outcome说明配置是否已生效、已回退或状态未知
+ */
+func (s *SSHSession) PushConfigTransaction(lines []string, checks []PostCheck, save bool, timeout int) (*TransactionResult, error) {
+	brand := s.GetSSHBrand()
+	driver := s.Driver()
+	if driver == nil {
+		return nil, errors.New("无法识别设备品牌，不能推送配置")
+	}
+	if !driver.TransactionCmds().Supported() || len(driver.ConfigEnterCmds()) == 0 {
+		return nil, fmt.Errorf("品牌%s不支持事务推送", brand)
+	}
+	result := &TransactionResult{Outcome: TxnNotApplied}
+	var output strings.Builder
+	defer func() { result.Output = output.String() }()
+	txn, err := s.transactionCmds(driver, timeout, &output)
+	if err != nil {
+		return result, err
+	}
+	if !txn.Supported() {
+		return result, fmt.Errorf("品牌%s一阶段生效的设备不支持事务推送", brand)
+	}
+	if errLine, err := s.sendConfirmCmds(driver, txn.Checkpoint, timeout, &output); err != nil {
+		result.DeviceError = errLine
+		return result, fmt.Errorf("创建检查点失败:%v", err)
+	}
+	for _, cmd := range driver.ConfigEnterCmds() {
+		res, errLine, err := s.sendConfigLine(driver, cmd, timeout)
+		output.WriteString(res)
+		if err != nil {
+			result.FailedLine, result.DeviceError = cmd, errLine
+			s.abortConfig(driver, timeout, &output)
+			return result, fmt.Errorf("进入配置模式失败:%v", err)
+		}
+	}
+	//下发配置并提交，失败时提交前放弃候选配置，配置即时生效的品牌回退到检查点
+	failed := func(line, errLine string, err error) (*TransactionResult, error) {
+		result.FailedLine, result.DeviceError = line, errLine
+		s.rollbackConfig(driver, txn, true, timeout, result, &output)
+		return result, fmt.Errorf("配置下发失败:%s,%v", line, err)
+	}
+	for _, line := range configPushLines(driver, lines) {
+		res, errLine, err := s.sendConfigLine(driver, line, timeout)
+		output.WriteString(res)
+		if err != nil {
+			return failed(line, errLine, err)
+		}
+		result.Applied = append(result.Applied, line)
+	}
+	if errLine, err := s.sendConfirmCmds(driver, txn.Commit, timeout, &output); err != nil {
+		return failed(strings.Join(txn.Commit, ";"), errLine, err)
+	}
+	result.Outcome = TxnApplied
+	for _, cmd := range driver.ConfigExitCmds() {
+		if errLine, err := s.sendConfirmCmds(driver, []string{cmd}, timeout, &output); err != nil {
+			result.FailedLine, result.DeviceError = cmd, errLine
+			s.rollbackConfig(driver, txn, false, timeout, result, &output)
+			return result, fmt.Errorf("退出配置模式失败:%v", err)
+		}
+	}
+	for _, check := range checks {
+		if err := s.runPostCheck(driver, check, timeout, &output); err != nil {
+			result.FailedCheck = check.Cmd
+			s.rollbackConfig(driver, txn, false, timeout, result, &output)
+			return result, fmt.Errorf("推送后检查失败:%s,%v", check.Cmd, err)
+		}
+	}
+	if !save {
+		return result, nil
+	}
+	return result, s.saveConfig(driver, timeout, &result.PushResult, &output)
+}
+
+/**
+ * 选择设备使用的事务命令：定义了TwoStagePattern的品牌先进入配置模式查看提示符，一阶段生效时改用OneStage，未定义OneStage时返回空的命令
+ * @param	timeout，读取单行回显的超时时间（秒），output，记录回显
+ * @return  事务命令和进入配置模式的错误
+ */
+func (s *SSHSession) transactionCmds(driver Driver, timeout int, output *strings.Builder) (TransactionCmds, error) {
+	txn := driver.TransactionCmds()
+	if driver.TwoStagePattern() == "" {
+		return txn, nil
+	}
+	twoStage := false
+	for _, cmd := range driver.ConfigEnterCmds() {
+		res, _, err := s.sendConfigLine(driver, cmd, timeout)
+		output.WriteString(res)
+		if err != nil {
+			s.abortConfig(driver, timeout, output)
+			return txn, fmt.Errorf("进入配置模式失败:%v", err)
+		}
+		twoStage = isTwoStage(driver, res)
+	}
+	s.abortConfig(driver, timeout, output)
+	switch {
+	case twoStage:
+		return txn, nil
+	case txn.OneStage == nil:
+		return TransactionCmds{}, nil
+	default:
+		oneStage := *txn.OneStage
+		oneStage.Answers = txn.Answers
+		return oneStage, nil
+	}
+}
+
+/**
+ * 恢复到推送前的状态，恢复成功时Outcome为rolled_back，否则为unknown
+ * @param	txn，设备使用的事务命令，inConfig，当前是否仍在配置模式中（尚未提交）
+ */
+func (s *SSHSession) rollbackConfig(driver Driver, txn TransactionCmds, inConfig bool, timeout int, result *TransactionResult, output *strings.Builder) {
+	var err error
+	if inConfig {
+		if len(txn.Commit) > 0 {
+			//尚未提交的候选配置直接放弃即可
+			_, err = s.sendConfirmCmds(driver, txn.Discard, timeout, output)
+			s.abortConfig(driver, timeout, output)
+			s.finishRollback(result, err)
+			return
+		}
+		s.abortConfig(driver, timeout, output)
+	}
+	if len(txn.Rollback) == 0 {
+		err = errors.New("配置已生效且不支持回退")
+	} else {
+		_, err = s.sendConfirmCmds(driver, txn.Rollback, timeout, output)
+	}
+	s.finishRollback(result, err)
+}
+
+func (s *SSHSession) finishRollback(result *TransactionResult, err error) {
+	if err != nil {
+		result.Outcome, result.RollbackError = TxnUnknown, err.Error()
+		LogError("恢复配置失败:%s", err.Error())
+		return
+	}
+	result.Outcome = TxnRolledBack
+}
+
+// 执行推送后的检查命令
+func (s *SSHSession) runPostCheck(driver Driver, check PostCheck, timeout int, output *strings.Builder) error {
+	sendCmd := driver.FormatCmd(check.Cmd)
+	s.WriteChannel(sendCmd)
+	res, ok := s.ReadChannelTiming(timeout)
+	output.WriteString(res)
+	if errLine, failed := MatchDriverError(driver, res); failed {
+		return errors.New(errLine)
+	}
+	if !ok {
+		return errors.New("读取命令回显超时")
+	}
+	res = filterResult(res, sendCmd, nil)
+	if check.Expect != "" {
+		re, err := regexp.Compile(check.Expect)
+		if err != nil {
+			return fmt.Errorf("Expect正则错误:%v", err)
+		}
+		if !re.MatchString(res) {
+			return fmt.Errorf("回显不匹配%s", check.Expect)
+		}
+	}
+	if check.Reject != "" {
+		re, err := regexp.Compile(check.Reject)
+		if err != nil {
+			return fmt.Errorf("Reject正则错误:%v", err)
+		}
+		if re.MatchString(res) {
+			return fmt.Errorf("回显匹配%s", check.Reject)
+		}
+	}
+	return nil
+}
+
+// 依次执行命令，按Driver的TransactionCmds应答确认提示，任一命令失败或出现未定义应答的提示即停止，返回匹配到的错误行
+func (s *SSHSession) sendConfirmCmds(driver Driver, cmds []string, timeout int, output *strings.Builder) (string, error) {
+	txn := driver.TransactionCmds()
+	for _, cmd := range cmds {
+		s.WriteChannel(cmd)
+		confirmed := 0
+		for {
+			res, confirm, ok := s.readChannelConfirm(timeout)
+			output.WriteString(res)
+			if errLine, failed := MatchDriverError(driver, res); failed {
+				return errLine, fmt.Errorf("%s:%s", cmd, errLine)
+			}
+			if !ok {
+				return "", fmt.Errorf("%s:读取命令回显超时", cmd)
+			}
+			if !confirm {
+				break
+			}
+			answer, found := txn.answer(cmd, res)
+			if !found {
+				//不确定应答时用Ctrl+C中断命令，不能默认确认
+				s.WriteChannel("\x03")
+				res, _ := s.ReadChannelTiming(timeout)
+				output.WriteString(res)
+				return "", fmt.Errorf("%s:未定义应答的确认提示", cmd)
+			}
+			if confirmed++; confirmed > maxConfirmTimes {
+				return "", fmt.Errorf("%s:确认次数过多", cmd)
+			}
+			s.WriteChannel(answer)
+		}
+	}
+	return "", nil
+}
+
+/**
+ * 从输出管道中读取回显，直到出现提示符或确认提示，与ReadChannelTiming相同，超过timeout仍未出现则认为超时
+ * @param	timeout,超时时间（秒）
+ * @return 	回显，是否为确认提示，bool为false表示超时退出
+ */
+func (s *SSHSession) readChannelConfirm(timeout int) (string, bool, bool) {
+	output := ""
+	reg := promptRegexp(s.brand)
+	loopDelay := 100
+	loops := timeout * 1000 / loopDelay
+	for i := 0; i < loops; i++ {
+		time.Sleep(time.Millisecond * time.Duration(loopDelay))
+		newData := s.readChannelData()
+		if newData == "" {
+			continue
+		}
+		output += newData
+		for _, prompt := range confirmPrompts {
+			if prompt.MatchString(output) {
+				return output, true, true
+			}
+		}
+		if reg.MatchString(output) {
+			return output, false, true
+		}
+	}
+	return output, false, false
+}
@@ -0,0 +1,180 @@
+package arkssh
+
+import (
+	"strings"
+	"testing"
+)
+
+// 在huaweiCEReply的基础上增加回退和检查命令的回显
+func huaweiTxnReply(cmd string) string {
+	switch {
+	case cmd == "rollback configuration last 1":
+		return "Warning: The configuration will be rolled back. Continue? [Y/N]:"
+	case cmd == "display bgp peer":
+		return " 10.0.0.2  4  200  Idle\r\n<CE-01>"
+	case strings.HasPrefix(cmd, "display "):
+		return " 10.0.0.2  4  200  Established\r\n<CE-01>"
+	default:
+		return huaweiCEReply(cmd)
+	}
+}
+
+func TestPushConfigTransaction(t *testing.T) {
+	s, received := newScriptedSession(HUAWEI, huaweiTxnReply)
+	checks := []PostCheck{{Cmd: "display bgp peer verbose", Expect: "Established"}}
+	result, err := s.PushConfigTransaction([]string{"bgp 100", "peer 10.0.0.2 as-number 200"}, checks, false, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	//先进入系统视图查看提示符，确认为两阶段提交
+	want := []string{"system-view", "return", "system-view", "bgp 100", "peer 10.0.0.2 as-number 200", "commit", "return", "display bgp peer verbose"}
+	if strings.Join(*received, "|") != strings.Join(want, "|") {
+		t.Fatalf("sent %q, want %q", *received, want)
+	}
+	if result.Outcome != TxnApplied || len(result.Applied) != 2 {
+		t.Fatalf("result got %+v", result)
+	}
+}
+
+func TestPushConfigTransactionDiscard(t *testing.T) {
+	s, received := newScriptedSession(HUAWEI, huaweiTxnReply)
+	result, err := s.PushConfigTransaction([]string{"bgp 100", "bad peer"}, nil, true, 1)
+	if err == nil {
+		t.Fatal("push should fail")
+	}
+	//提交前失败时放弃候选配置，不需要回退
+	want := []string{"system-view", "return", "system-view", "bgp 100", "bad peer", "clear configuration candidate", "return"}
+	if strings.Join(*received, "|") != strings.Join(want, "|") {
+		t.Fatalf("sent %q, want %q", *received, want)
+	}
+	if result.Outcome != TxnRolledBack || result.FailedLine != "bad peer" || result.Saved {
+		t.Fatalf("result got %+v", result)
+	}
+}
+
+func TestPushConfigTransactionRollback(t *testing.T) {
+	s, received := newScriptedSession(HUAWEI, huaweiTxnReply)
+	checks := []PostCheck{{Cmd: "display bgp peer", Reject: "Idle"}}
+	result, err := s.PushConfigTransaction([]string{"bgp 100"}, checks, true, 1)
+	if err == nil {
+		t.Fatal("post check should fail")
+	}
+	//检查失败时回退已提交的配置，并自动应答确认提示
+	want := []string{"system-view", "return", "system-view", "bgp 100", "commit", "return", "display bgp peer", "rollback configuration last 1", "Y"}
+	if strings.Join(*received, "|") != strings.Join(want, "|") {
+		t.Fatalf("sent %q, want %q", *received, want)
+	}
+	if result.Outcome != TxnRolledBack || result.FailedCheck != "display bgp peer" || result.Saved {
+		t.Fatalf("result got %+v", result)
+	}
+}
+
+func TestPushConfigTransactionUnknown(t *testing.T) {
+	s, received := newScriptedSession(H3C, func(cmd string) string {
+		switch {
+		case cmd == "save flash:/arkssh_checkpoint.cfg":
+			return "The current configuration will be saved to flash:/arkssh_checkpoint.cfg. Continue? [Y/N]:"
+		case strings.HasPrefix(cmd, "configuration replace"), strings.HasPrefix(cmd, "bad"):
+			return "% Unrecognized command found at '^' position.\r\n[SW-02]"
+		case cmd == "system-view":
+			return "\r\n[SW-02]"
+		default:
+			return "\r\n<SW-02>"
+		}
+	})
+	result, err := s.PushConfigTransaction([]string{"bad command"}, nil, false, 1)
+	if err == nil {
+		t.Fatal("push should fail")
+	}
+	//配置即时生效的品牌退出配置模式后按检查点回退，回退失败时状态未知
+	want := []string{"save flash:/arkssh_checkpoint.cfg", "Y", "system-view", "bad command", "return", "system-view", "configuration replace file flash:/arkssh_checkpoint.cfg"}
+	if strings.Join(*received, "|") != strings.Join(want, "|") {
+		t.Fatalf("sent %q, want %q", *received, want)
+	}
+	if result.Outcome != TxnUnknown || result.RollbackError == "" {
+		t.Fatalf("result got %+v", result)
+	}
+	s, _ = newScriptedSession(FORTINET, huaweiReply)
+	if _, err := s.PushConfigTransaction([]string{"config system global"}, nil, false, 1); err == nil {
+		t.Fatal("fortinet should not support transaction push")
+	}
+}
+
+func TestPushConfigTransactionCommitRejected(t *testing.T) {
+	s, received := newScriptedSession(HUAWEI, func(cmd string) string {
+		if cmd == "commit" {
+			return "Error: The configuration failed to be committed.\r\n[*CE-01]"
+		}
+		return huaweiTxnReply(cmd)
+	})
+	result, err := s.PushConfigTransaction([]string{"bgp 100"}, nil, false, 1)
+	if err == nil {
+		t.Fatal("commit should fail")
+	}
+	//提交失败时候选配置未生效，放弃后即为已恢复
+	want := []string{"system-view", "return", "system-view", "bgp 100", "commit", "clear configuration candidate", "return"}
+	if strings.Join(*received, "|") != strings.Join(want, "|") {
+		t.Fatalf("sent %q, want %q", *received, want)
+	}
+	if result.Outcome != TxnRolledBack || result.FailedLine != "commit" {
+		t.Fatalf("result got %+v", result)
+	}
+}
+
+func TestPushConfigTransactionOneStage(t *testing.T) {
+	//华为S系列一阶段生效，没有commit命令，按保存的检查点文件回退
+	s, received := newScriptedSession(HUAWEI, func(cmd string) string {
+		switch {
+		case cmd == "commit":
+			return "                  ^\r\nError: Unrecognized command found at '^' position.\r\n[SW-01]"
+		case cmd == "save arkssh_checkpoint.cfg":
+			return "Are you sure to save the configuration to flash:/arkssh_checkpoint.cfg? (y/n)[n]:"
+		case cmd == "rollback configuration to file arkssh_checkpoint.cfg":
+			return "Warning: The current configuration will be replaced. Continue? [Y/N]:"
+		case strings.HasPrefix(cmd, "display "):
+			return huaweiTxnReply(cmd)
+		default:
+			return huaweiReply(cmd)
+		}
+	})
+	checks := []PostCheck{{Cmd: "display bgp peer", Reject: "Idle"}}
+	result, err := s.PushConfigTransaction([]string{"bgp 100"}, checks, false, 1)
+	if err == nil {
+		t.Fatal("post check should fail")
+	}
+	want := []string{"system-view", "return", "save arkssh_checkpoint.cfg", "Y", "system-view", "bgp 100", "return",
+		"display bgp peer", "rollback configuration to file arkssh_checkpoint.cfg", "Y"}
+	if strings.Join(*received, "|") != strings.Join(want, "|") {
+		t.Fatalf("sent %q, want %q", *received, want)
+	}
+	if result.Outcome != TxnRolledBack {
+		t.Fatalf("result got %+v", result)
+	}
+}
+
+func TestSendConfirmCmdsAnswers(t *testing.T) {
+	//H3C替换配置时询问是否保存当前配置，应答N
+	s, received := newScriptedSession(H3C, func(cmd string) string {
+		switch {
+		case strings.HasPrefix(cmd, "configuration replace"):
+			return "Current configuration will be lost, save current configuration? [Y/N]:"
+		case cmd == "display clock":
+			return "Continue? [Y/N]:"
+		default:
+			return "\r\n[SW-02]"
+		}
+	})
+	var output strings.Builder
+	driver := s.Driver()
+	if _, err := s.sendConfirmCmds(driver, []string{"configuration replace file flash:/arkssh_checkpoint.cfg"}, 1, &output); err != nil {
+		t.Fatal(err)
+	}
+	//未定义应答的确认提示中断命令，不默认确认
+	if _, err := s.sendConfirmCmds(driver, []string{"display clock"}, 1, &output); err == nil {
+		t.Fatal("undefined prompt should fail")
+	}
+	want := []string{"configuration replace file flash:/arkssh_checkpoint.cfg", "N", "display clock", "\x03"}
+	if strings.Join(*received, "|") != strings.Join(want, "|") {
+		t.Fatalf("sent %q, want %q", *received, want)
+	}
+}